@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// anthropicBase is Anthropic's Messages API used by DIFFSCRIBE_PROVIDER=anthropic.
+	anthropicBase    = "https://api.anthropic.com/v1"
+	anthropicVersion = "2023-06-01"
+
+	// anthropicMaxTokens fills in max_tokens, which the Messages API requires, for requests
+	// that do not set one.
+	anthropicMaxTokens = 2000
+
+	// anthropicMaxTemperature is the highest temperature the Messages API accepts; larger
+	// DIFFSCRIBE_TEMPERATURE values, valid for other providers, are lowered to it.
+	anthropicMaxTemperature = 1.0
+)
+
+// anthropicChat is the Generator for Anthropic's Messages API.
+type anthropicChat struct {
+	apiKey string
+}
+
+// Generate performs a single Messages API call against model. System messages become the
+// top-level system prompt, and JSON requests are answered by prefilling the reply with "{".
+func (g anthropicChat) Generate(model string, creq completionRequest) (GenerationResult, error) {
+	var system []string
+	messages := make([]chatMessage, 0, len(creq.Messages)+1)
+	for _, m := range creq.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	prefill := ""
+	if creq.JSON && len(messages) > 0 && messages[len(messages)-1].Role != "assistant" {
+		prefill = "{"
+		messages = append(messages, chatMessage{Role: "assistant", Content: prefill})
+	}
+
+	maxTokens := creq.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicMaxTokens
+	}
+	reqBody := map[string]any{
+		"model":       model,
+		"messages":    messages,
+		"max_tokens":  maxTokens,
+		"temperature": min(creq.Temperature, anthropicMaxTemperature),
+	}
+	if creq.TopP > 0 {
+		reqBody["top_p"] = creq.TopP
+	}
+	if len(system) > 0 {
+		reqBody["system"] = strings.Join(system, "\n\n")
+	}
+	for k, v := range creq.Extra {
+		if k != "model" && k != "messages" && k != "system" {
+			reqBody[k] = v
+		}
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, anthropicBase+"/messages", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	req.Header.Set("x-api-key", g.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return GenerationResult{}, newModelStatusError("Anthropic", resp, respBytes)
+	}
+
+	var result struct {
+		Model   string `json:"model"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return GenerationResult{}, err
+	}
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return GenerationResult{}, fmt.Errorf("no text content returned from Anthropic API")
+	}
+	if result.Model == "" {
+		result.Model = model
+	}
+	return GenerationResult{
+		Content:      prefill + text.String(),
+		Model:        result.Model,
+		FinishReason: result.StopReason,
+		Usage: tokenUsage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+		},
+		Truncated: result.StopReason == "max_tokens",
+	}, nil
+}
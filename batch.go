@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// runOutcome is the result of processing a single PR.
+type runOutcome int
+
+const (
+	outcomeSucceeded runOutcome = iota
+	outcomeSkipped
+	outcomeFailed
+)
+
+// String returns the outcome as used in logs and summaries.
+func (o runOutcome) String() string {
+	switch o {
+	case outcomeSucceeded:
+		return "succeeded"
+	case outcomeSkipped:
+		return "skipped"
+	}
+	return "failed"
+}
+
+// batchResult records how one PR of a batch run went.
+type batchResult struct {
+	PRNumber string
+	Outcome  runOutcome
+	Err      error
+}
+
+// batchSummary counts the outcomes of a batch run.
+type batchSummary struct {
+	Succeeded, Skipped, Failed int
+}
+
+// summarizeBatch tallies the results of a batch run.
+func summarizeBatch(results []batchResult) batchSummary {
+	var s batchSummary
+	for _, r := range results {
+		switch r.Outcome {
+		case outcomeSucceeded:
+			s.Succeeded++
+		case outcomeSkipped:
+			s.Skipped++
+		default:
+			s.Failed++
+		}
+	}
+	return s
+}
+
+// ExitCode is 0 when every PR succeeded or was skipped, and 1 when any PR hard-failed.
+func (s batchSummary) ExitCode() int {
+	if s.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// String renders the final summary line.
+func (s batchSummary) String() string {
+	return fmt.Sprintf("Batch summary: %d succeeded, %d skipped, %d failed", s.Succeeded, s.Skipped, s.Failed)
+}
+
+// runBatch processes every open PR of the repository with process, stopping at the first
+// failure when failFast is set. The error is only for failing to list the PRs.
+func runBatch(cfg Config, failFast bool, process func(Config) (runOutcome, error)) ([]batchResult, error) {
+	prs, err := listOpenPullRequests(cfg.Repository, cfg.GitHubToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open PRs: %w", err)
+	}
+	log.Printf("Processing %d open PR(s) in %s", len(prs), cfg.Repository)
+	return processBatch(cfg, prs, failFast, process), nil
+}
+
+// processBatch runs process for each of prs in turn, stopping at the first failure when
+// failFast is set.
+func processBatch(cfg Config, prs []PullRequest, failFast bool, process func(Config) (runOutcome, error)) []batchResult {
+	var results []batchResult
+	for _, pr := range prs {
+		prCfg := cfg
+		prCfg.PRNumber = strconv.Itoa(pr.Number)
+		prCfg.PRBody = pr.Body
+
+		log.Printf("--- PR #%d: %s", pr.Number, pr.Title)
+		outcome, err := process(prCfg)
+		if err != nil {
+			outcome = outcomeFailed
+			log.Printf("PR #%d failed: %v", pr.Number, err)
+		}
+		results = append(results, batchResult{PRNumber: prCfg.PRNumber, Outcome: outcome, Err: err})
+
+		if outcome == outcomeFailed && failFast {
+			log.Printf("Stopping after PR #%d failed (--fail-fast)", pr.Number)
+			break
+		}
+	}
+	return results
+}
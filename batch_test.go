@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// simulatedRun reports a fixed outcome per PR number, as run would.
+func simulatedRun(outcomes map[string]runOutcome) func(Config) (runOutcome, error) {
+	return func(cfg Config) (runOutcome, error) {
+		if outcomes[cfg.PRNumber] == outcomeFailed {
+			return outcomeFailed, errors.New("model unavailable")
+		}
+		return outcomes[cfg.PRNumber], nil
+	}
+}
+
+func TestProcessBatch(t *testing.T) {
+	prs := []PullRequest{{Number: 1}, {Number: 2}, {Number: 3}, {Number: 4}}
+	outcomes := map[string]runOutcome{"1": outcomeSucceeded, "2": outcomeFailed, "3": outcomeSkipped, "4": outcomeSucceeded}
+
+	tests := []struct {
+		name     string
+		outcomes map[string]runOutcome
+		failFast bool
+		want     batchSummary
+		exitCode int
+	}{
+		{name: "mixed outcomes", outcomes: outcomes, want: batchSummary{Succeeded: 2, Skipped: 1, Failed: 1}, exitCode: 1},
+		{name: "fail fast stops at the first failure", outcomes: outcomes, failFast: true, want: batchSummary{Succeeded: 1, Failed: 1}, exitCode: 1},
+		{
+			name:     "successes and skips exit 0",
+			outcomes: map[string]runOutcome{"1": outcomeSucceeded, "2": outcomeSkipped, "3": outcomeSkipped, "4": outcomeSucceeded},
+			want:     batchSummary{Succeeded: 2, Skipped: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := processBatch(Config{}, prs, tt.failFast, simulatedRun(tt.outcomes))
+			got := summarizeBatch(results)
+			if got != tt.want {
+				t.Errorf("summary = %+v, want %+v", got, tt.want)
+			}
+			if code := got.ExitCode(); code != tt.exitCode {
+				t.Errorf("exit code = %d, want %d", code, tt.exitCode)
+			}
+			for _, r := range results {
+				if (r.Outcome == outcomeFailed) != (r.Err != nil) {
+					t.Errorf("PR #%s: outcome %s with error %v", r.PRNumber, r.Outcome, r.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchSummaryString(t *testing.T) {
+	s := batchSummary{Succeeded: 3, Skipped: 1, Failed: 2}
+	if got, want := s.String(), "Batch summary: 3 succeeded, 1 skipped, 2 failed"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// bedrockChat is the Generator for Amazon Bedrock's Converse API, which serves Claude, Titan
+// and the other Bedrock text models behind one request shape.
+type bedrockChat struct {
+	region string
+	creds  awsCredentials
+}
+
+// bedrockContent is one content block of a Converse message.
+type bedrockContent struct {
+	Text string `json:"text"`
+}
+
+// bedrockMessage is one Converse message.
+type bedrockMessage struct {
+	Role    string           `json:"role"`
+	Content []bedrockContent `json:"content"`
+}
+
+// Generate performs a single Converse call against model, a Bedrock model ID such as
+// anthropic.claude-3-5-haiku-20241022-v1:0. System messages become the system prompt and
+// extra parameters are passed as additionalModelRequestFields.
+func (g bedrockChat) Generate(model string, creq completionRequest) (GenerationResult, error) {
+	var system []bedrockContent
+	var messages []bedrockMessage
+	for _, m := range creq.Messages {
+		if m.Role == "system" {
+			system = append(system, bedrockContent{Text: m.Content})
+			continue
+		}
+		messages = append(messages, bedrockMessage{Role: m.Role, Content: []bedrockContent{{Text: m.Content}}})
+	}
+
+	inference := map[string]any{"temperature": creq.Temperature}
+	if creq.MaxTokens > 0 {
+		inference["maxTokens"] = creq.MaxTokens
+	}
+	if creq.TopP > 0 {
+		inference["topP"] = creq.TopP
+	}
+	reqBody := map[string]any{
+		"messages":        messages,
+		"inferenceConfig": inference,
+	}
+	if len(system) > 0 {
+		reqBody["system"] = system
+	}
+	if len(creq.Extra) > 0 {
+		reqBody["additionalModelRequestFields"] = creq.Extra
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	host := "bedrock-runtime." + g.region + ".amazonaws.com"
+	path := "/model/" + awsURIEncode(model) + "/converse"
+	req, err := http.NewRequest(http.MethodPost, "https://"+host+path, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	req.URL.Path = "/model/" + model + "/converse"
+	req.URL.RawPath = path
+	req.Header.Set("Content-Type", "application/json")
+	signAWSRequest(req, bodyBytes, g.creds, g.region, "bedrock", time.Now())
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return GenerationResult{}, newModelStatusError("Amazon Bedrock", resp, respBytes)
+	}
+
+	var result struct {
+		Output struct {
+			Message bedrockMessage `json:"message"`
+		} `json:"output"`
+		StopReason string `json:"stopReason"`
+		Usage      struct {
+			InputTokens  int `json:"inputTokens"`
+			OutputTokens int `json:"outputTokens"`
+			TotalTokens  int `json:"totalTokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return GenerationResult{}, err
+	}
+	var text strings.Builder
+	for _, block := range result.Output.Message.Content {
+		text.WriteString(block.Text)
+	}
+	if text.Len() == 0 {
+		return GenerationResult{}, fmt.Errorf("no text content returned from Amazon Bedrock API")
+	}
+	return GenerationResult{
+		Content:      text.String(),
+		Model:        model,
+		FinishReason: result.StopReason,
+		Usage: tokenUsage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		},
+		Truncated: result.StopReason == "max_tokens",
+	}, nil
+}
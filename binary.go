@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// gitBinaryLiteralPattern matches the "literal <size>" line of a GIT binary patch, giving
+// the size of the new content in bytes.
+var gitBinaryLiteralPattern = regexp.MustCompile(`(?m)^literal (\d+)$`)
+
+// imageExtensions are the file extensions reported as images rather than binary files.
+var imageExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".ico": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".avif": true, ".heic": true,
+}
+
+// isBinaryFileDiff reports whether a file block carries a binary change: git's "Binary files
+// ... differ" line or a GIT binary patch.
+func isBinaryFileDiff(text string) bool {
+	return strings.Contains(text, "\nBinary files ") || strings.Contains(text, "\nGIT binary patch\n")
+}
+
+// collapseBinaryFiles replaces every binary file block with a one-line note such as
+// "(added image assets/logo.png, 45KB)", keeping its header so the model still knows the file
+// changed, and returns how many it collapsed.
+func collapseBinaryFiles(diff string) (string, int) {
+	files := splitDiffFiles(diff)
+	n := 0
+	for i, f := range files {
+		if f.Path == "" || !isBinaryFileDiff(f.Text) {
+			continue
+		}
+		files[i].Text = fileHeaderLine(f) + binaryNote(f) + "\n"
+		n++
+	}
+	if n == 0 {
+		return diff, 0
+	}
+	return joinDiffFiles(files), n
+}
+
+// binaryNote describes one binary change: what happened, whether it is an image, and its
+// size when a GIT binary patch records it.
+func binaryNote(f fileDiff) string {
+	action := "modified"
+	switch {
+	case strings.Contains(f.Text, "\nnew file mode"):
+		action = "added"
+	case strings.Contains(f.Text, "\ndeleted file mode"):
+		action = "deleted"
+	}
+	kind := "binary file"
+	if imageExtensions[strings.ToLower(path.Ext(f.Path))] {
+		kind = "image"
+	}
+	note := fmt.Sprintf("(%s %s %s", action, kind, f.Path)
+	if m := gitBinaryLiteralPattern.FindStringSubmatch(f.Text); m != nil && action != "deleted" {
+		if size, err := strconv.Atoi(m[1]); err == nil {
+			note += ", " + formatSize(size)
+		}
+	}
+	return note + ")"
+}
+
+// formatSize renders a byte count as B, KB or MB.
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%dKB", (n+1<<9)>>10)
+	}
+	return fmt.Sprintf("%dB", n)
+}
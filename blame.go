@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// blameCommitsPerFile is how many recent commits are inspected for each changed file.
+const blameCommitsPerFile = 10
+
+// blameReviewerCount is how many suggested reviewers suggestReviewersByBlame returns.
+const blameReviewerCount = 3
+
+// suggestReviewersByBlame suggests reviewers from who most recently changed the given files:
+// the authors of each file's last commits on the default branch are counted, weighting newer
+// commits higher, and the logins are returned best first. Bots are ignored. The caller caps
+// paths, since every path costs one API call.
+func suggestReviewersByBlame(repo string, paths []string, token string) []string {
+	scores := make(map[string]int)
+	for _, path := range paths {
+		commits, err := fetchPathCommits(repo, path, blameCommitsPerFile, token)
+		if err != nil {
+			log.Printf("Warning: failed to fetch history of %s: %v", path, err)
+			continue
+		}
+		for i, c := range commits {
+			if c.Author == nil || c.Author.Login == "" || strings.HasSuffix(c.Author.Login, "[bot]") {
+				continue
+			}
+			scores[c.Author.Login] += blameCommitsPerFile - i
+		}
+	}
+
+	logins := make([]string, 0, len(scores))
+	for login := range scores {
+		logins = append(logins, login)
+	}
+	sort.Slice(logins, func(a, b int) bool {
+		if scores[logins[a]] != scores[logins[b]] {
+			return scores[logins[a]] > scores[logins[b]]
+		}
+		return logins[a] < logins[b]
+	})
+	return logins
+}
+
+// topBlameReviewers drops the PR author from the ranked logins and formats the first
+// blameReviewerCount as @-mentions.
+func topBlameReviewers(logins []string, author string) []string {
+	var top []string
+	for _, login := range logins {
+		if strings.EqualFold(login, author) {
+			continue
+		}
+		top = append(top, "@"+login)
+		if len(top) == blameReviewerCount {
+			break
+		}
+	}
+	return top
+}
+
+// blameNote suggests the recent committers to the first DIFFSCRIBE_BLAME_MAX_FILES changed
+// files, other than the PR author, as reviewers with DIFFSCRIBE_BLAME_REVIEWERS.
+func blameNote(rc *runContext) string {
+	if !rc.cfg.BlameReviewers {
+		return ""
+	}
+	paths := rc.changedPaths
+	if len(paths) > rc.cfg.BlameMaxFiles {
+		paths = paths[:rc.cfg.BlameMaxFiles]
+	}
+	author := ""
+	if pr, err := rc.pullRequest(); err == nil {
+		author = pr.User.Login
+	}
+	reviewers := topBlameReviewers(suggestReviewersByBlame(rc.cfg.Repository, paths, rc.cfg.GitHubToken), author)
+	if len(reviewers) == 0 {
+		return ""
+	}
+	log.Printf("Suggested reviewers from recent history: %s", strings.Join(reviewers, ", "))
+	return "**Recently active in these files:** " + strings.Join(reviewers, ", ")
+}
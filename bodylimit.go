@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodyChars is GitHub's limit on the length of a PR body, in characters.
+const maxBodyChars = 65536
+
+// bodyTrimmedNote replaces the cut-off end of a section shortened to fit maxBodyChars.
+const bodyTrimmedNote = "\n\n_(trimmed to fit GitHub's description size limit)_\n"
+
+// enforceBodyLimit shortens body to at most limit characters. Sections whose canonical key
+// is in authorKeys hold the author's own text, so generated sections are trimmed first, from
+// the last one backwards; author sections are only trimmed as a last resort, with a warning.
+func enforceBodyLimit(body string, authorKeys map[string]bool, synonyms map[string]string, limit int) string {
+	over := utf8.RuneCountInString(body) - limit
+	if over <= 0 {
+		return body
+	}
+
+	sections := splitSections(body)
+	isAuthor := func(s section) bool {
+		return s.Heading != "" && authorKeys[canonicalizeHeading(s.Title(), synonyms)]
+	}
+	for _, authorPass := range []bool{false, true} {
+		for i := len(sections) - 1; i >= 0 && over > 0; i-- {
+			if isAuthor(sections[i]) != authorPass || sections[i].Heading == "" {
+				continue
+			}
+			before := utf8.RuneCountInString(sections[i].Body)
+			if before <= utf8.RuneCountInString(bodyTrimmedNote) {
+				continue
+			}
+			sections[i].Body = trimSectionBody(sections[i].Body, before-over)
+			over -= before - utf8.RuneCountInString(sections[i].Body)
+			if authorPass {
+				log.Printf("Warning: trimmed the author's %q section to fit GitHub's %d character limit", sections[i].Title(), limit)
+			}
+		}
+	}
+
+	body = joinSections(sections)
+	if utf8.RuneCountInString(body) > limit {
+		log.Printf("Warning: description still exceeds %d characters after trimming sections; cutting it off", limit)
+		body = string([]rune(body)[:limit])
+	}
+	log.Printf("Description trimmed to fit GitHub's %d character limit", limit)
+	return body
+}
+
+// trimSectionBody cuts a section body to roughly maxChars characters at a line boundary,
+// ending it with bodyTrimmedNote. The note is counted, so the result may be empty of content.
+func trimSectionBody(body string, maxChars int) string {
+	keep := maxChars - utf8.RuneCountInString(bodyTrimmedNote)
+	if keep <= 0 {
+		return strings.TrimLeft(bodyTrimmedNote, "\n")
+	}
+	cut := string([]rune(body)[:keep])
+	if nl := strings.LastIndexByte(cut, '\n'); nl > 0 {
+		cut = cut[:nl]
+	}
+	return strings.TrimRight(cut, " \n") + bodyTrimmedNote
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// File kinds reported by fileKind.
+const (
+	kindSource = "source"
+	kindTest   = "test"
+	kindDocs   = "docs"
+)
+
+// testDirs are directory names whose files are tests.
+var testDirs = []string{"test", "tests", "__tests__", "spec", "testdata", "e2e"}
+
+// docExtensions are the extensions of documentation files.
+var docExtensions = map[string]bool{".md": true, ".mdx": true, ".rst": true, ".adoc": true, ".txt": true}
+
+// isTestFile reports whether p is a test file by name ("_test.go", ".spec.ts", "test_x.py",
+// "x_spec.rb") or by directory ("tests/", "__tests__/", ...).
+func isTestFile(p string) bool {
+	base := strings.ToLower(path.Base(p))
+	if strings.Contains(base, "_test.") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasSuffix(base, "_spec.rb") || (strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py")) {
+		return true
+	}
+	dirs := strings.Split(path.Dir(strings.ToLower(p)), "/")
+	for _, dir := range testDirs {
+		for _, d := range dirs {
+			if d == dir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDocFile reports whether p is documentation: a markdown or text file, or a file under a
+// docs/ or doc/ directory.
+func isDocFile(p string) bool {
+	p = strings.ToLower(p)
+	return docExtensions[path.Ext(p)] || strings.HasPrefix(p, "docs/") || strings.HasPrefix(p, "doc/") ||
+		strings.Contains(p, "/docs/")
+}
+
+// fileKind classifies a changed file as a test, documentation or source file; tests win over
+// docs, so test fixtures under testdata/ are counted as tests.
+func fileKind(p string) string {
+	switch {
+	case isTestFile(p):
+		return kindTest
+	case isDocFile(p):
+		return kindDocs
+	}
+	return kindSource
+}
+
+// classifyChanges groups changes by fileKind.
+func classifyChanges(changes FileChanges) map[string]FileChanges {
+	kinds := make(map[string]FileChanges)
+	for _, c := range changes {
+		kind := fileKind(c.Path)
+		kinds[kind] = append(kinds[kind], c)
+	}
+	return kinds
+}
+
+// formatClassification renders one line per kind with its file and line counts, followed
+// by the test files and how each changed, e.g.
+//
+//	Source: 4 file(s), +120/-30
+//	Tests: 1 file(s), +45/-0
+//	Docs: none
+//	Test files:
+//	- `cache/evict_test.go` (added, +45/-0)
+func formatClassification(changes FileChanges) string {
+	kinds := classifyChanges(changes)
+	var b strings.Builder
+	for _, k := range []struct{ kind, label string }{{kindSource, "Source"}, {kindTest, "Tests"}, {kindDocs, "Docs"}} {
+		files := kinds[k.kind]
+		if len(files) == 0 {
+			fmt.Fprintf(&b, "%s: none\n", k.label)
+			continue
+		}
+		additions, deletions := 0, 0
+		for _, c := range files {
+			additions += c.Additions
+			deletions += c.Deletions
+		}
+		fmt.Fprintf(&b, "%s: %d file(s), +%d/-%d\n", k.label, len(files), additions, deletions)
+	}
+	if tests := kinds[kindTest]; len(tests) > 0 {
+		b.WriteString("Test files:\n")
+		b.WriteString(formatTestChanges(tests))
+	}
+	return b.String()
+}
+
+// formatTestChanges lists the test files with their change type and line counts.
+func formatTestChanges(tests FileChanges) string {
+	var b strings.Builder
+	for _, c := range tests {
+		fmt.Fprintf(&b, "- `%s` (%s, +%d/-%d)\n", c.Path, c.Type, c.Additions, c.Deletions)
+	}
+	return b.String()
+}
+
+// testChangesHeading is the heading of the appended test change list.
+const testChangesHeading = "## Test changes"
+
+// appendTestChanges appends the test files of changes under "## Test changes", or a line
+// saying none changed, unless the body already has such a section.
+func appendTestChanges(body string, changes FileChanges) string {
+	for _, s := range splitSections(body) {
+		if sectionKey(s.Title()) == sectionKey(strings.TrimLeft(testChangesHeading, "# ")) {
+			return body
+		}
+	}
+	list := formatTestChanges(classifyChanges(changes)[kindTest])
+	if list == "" {
+		list = "No test files were added or modified.\n"
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + testChangesHeading + "\n" + list
+}
+
+// classificationPart splits the changed files into source, tests and docs for the prompt with
+// DIFFSCRIBE_CLASSIFY_FILES.
+func classificationPart(cfg Config, changes FileChanges) promptPart {
+	if !cfg.ClassifyFiles {
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Source, Test and Docs Changes", Text: formatClassification(changes)},
+		Instruction:  "State explicitly what test coverage the PR adds or modifies, naming the test files listed above, or that it changes no tests; do not claim tests that are not in the diff.",
+	}
+}
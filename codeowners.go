@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// codeownersPaths are the locations GitHub reads CODEOWNERS from, in priority order.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// readCodeowners returns the contents of the repository's CODEOWNERS file from the local
+// checkout, or "" when there is none.
+func readCodeowners() string {
+	for _, path := range codeownersPaths {
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data)
+		}
+	}
+	return ""
+}
+
+// matchCodeowners returns the owners of the given paths, in order of first appearance. As on
+// GitHub, the last CODEOWNERS rule matching a path wins.
+func matchCodeowners(paths []string, codeowners string) []string {
+	type rule struct {
+		pattern string
+		owners  []string
+	}
+	var rules []rule
+	for _, line := range strings.Split(codeowners, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rules = append(rules, rule{pattern: fields[0], owners: fields[1:]})
+	}
+
+	seen := make(map[string]bool)
+	var owners []string
+	for _, path := range paths {
+		for i := len(rules) - 1; i >= 0; i-- {
+			if !matchPathPattern(rules[i].pattern, path) {
+				continue
+			}
+			for _, owner := range rules[i].owners {
+				if !seen[owner] {
+					seen[owner] = true
+					owners = append(owners, owner)
+				}
+			}
+			break
+		}
+	}
+	return owners
+}
+
+// codeownersNote suggests the CODEOWNERS of the changed files as reviewers with
+// DIFFSCRIBE_SUGGEST_REVIEWERS.
+func codeownersNote(rc *runContext) string {
+	if !rc.cfg.SuggestReviewers {
+		return ""
+	}
+	owners := matchCodeowners(rc.changedPaths, readCodeowners())
+	if len(owners) == 0 {
+		return ""
+	}
+	log.Printf("Suggested reviewers from CODEOWNERS: %s", strings.Join(owners, ", "))
+	return "**Suggested reviewers:** " + strings.Join(owners, ", ")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// defaultCommitBudget caps the bytes of commit messages added to the prompt.
+const defaultCommitBudget = 3000
+
+// maxCommitBodyLines bounds how much of each commit body is kept.
+const maxCommitBodyLines = 6
+
+// commitTrailerPattern matches git trailers such as "Signed-off-by:" that carry no intent.
+var commitTrailerPattern = regexp.MustCompile(`(?i)^(signed-off-by|co-authored-by|reviewed-by|acked-by|change-id):`)
+
+// commitMessagesContext formats the messages of the PR's non-merge commits, oldest first, as
+// a bullet per subject with its body (trailers dropped) indented beneath. Commits beyond
+// budget bytes are only counted.
+func commitMessagesContext(commits []Commit, budget int) string {
+	var b strings.Builder
+	omitted := 0
+	for _, c := range commits {
+		if len(c.Parents) > 1 {
+			continue
+		}
+		entry := formatCommitMessage(c.Commit.Message)
+		if entry == "" {
+			continue
+		}
+		if omitted > 0 || b.Len()+len(entry) > budget {
+			omitted++
+			continue
+		}
+		b.WriteString(entry)
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&b, "- ... and %d more commit(s)\n", omitted)
+	}
+	return b.String()
+}
+
+// formatCommitMessage renders one commit message as "- subject" followed by up to
+// maxCommitBodyLines indented body lines.
+func formatCommitMessage(message string) string {
+	subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return ""
+	}
+	entry := "- " + subject + "\n"
+	kept := 0
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || commitTrailerPattern.MatchString(line) {
+			continue
+		}
+		if kept == maxCommitBodyLines {
+			entry += "  ...\n"
+			break
+		}
+		entry += "  " + line + "\n"
+		kept++
+	}
+	return entry
+}
+
+// commitMessagesPart adds the PR's commit messages to the prompt with
+// DIFFSCRIBE_COMMIT_MESSAGES.
+func commitMessagesPart(rc *runContext) promptPart {
+	if !rc.cfg.CommitMessages {
+		return promptPart{}
+	}
+	commits, err := rc.prCommits()
+	if err != nil {
+		log.Printf("Warning: failed to fetch PR commits for the prompt: %v", err)
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Commit Messages", Text: commitMessagesContext(commits, rc.cfg.CommitBudget)},
+		Instruction:  "Use the commit messages to explain why the change was made, but describe only changes the diff shows.",
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// maxSummarizedCommits caps the commits summarised one by one; larger PRs fall back to the
+// regular diff reduction.
+const maxSummarizedCommits = 50
+
+// maxCommitDiffBytes bounds the diff sent to the model for one commit summary.
+const maxCommitDiffBytes = 12000
+
+// commitSummaryInstruction tells the model the diff section holds commit summaries.
+const commitSummaryInstruction = "The diff was too large to include, so the Code Diff section holds a summary of each commit, oldest first. Describe the net result of the PR as a whole, not each commit in turn."
+
+// changedLineCount is the number of added and removed lines in diff.
+func changedLineCount(diff string) int {
+	n := 0
+	for _, f := range splitDiffFiles(diff) {
+		n += f.Additions + f.Deletions
+	}
+	return n
+}
+
+// summarizeCommits summarises the diff of each non-merge commit of the PR separately and
+// returns the summaries, oldest first, to stand in for a diff too large to reduce well. The
+// commit diffs are filtered like the PR diff. When the summaries exceed maxSize they are
+// condensed as in mapReduceDiff.
+func summarizeCommits(rc *runContext, maxSize int) (string, error) {
+	commits, err := rc.prCommits()
+	if err != nil {
+		return "", err
+	}
+	var own []Commit
+	for _, c := range commits {
+		if len(c.Parents) == 1 {
+			own = append(own, c)
+		}
+	}
+	if len(own) == 0 {
+		return "", fmt.Errorf("the PR has no non-merge commits")
+	}
+	if len(own) > maxSummarizedCommits {
+		return "", fmt.Errorf("the PR has %d commits, more than the %d summarised one by one", len(own), maxSummarizedCommits)
+	}
+
+	cfg := rc.cfg
+	var b strings.Builder
+	for i, c := range own {
+		subject, _, _ := strings.Cut(strings.TrimSpace(c.Commit.Message), "\n")
+		short := c.SHA[:min(len(c.SHA), 7)]
+		log.Printf("Summarising commit %d/%d (%s)...", i+1, len(own), short)
+		diff, err := fetchCompareDiff(cfg.Repository, c.Parents[0].SHA, "..", c.SHA, cfg.GitHubToken)
+		if err != nil {
+			return "", fmt.Errorf("commit %s: %w", short, err)
+		}
+		diff = filterPromptDiff(redactPaths(diff, cfg.RedactPaths), cfg)
+		if strings.TrimSpace(diff) == "" {
+			fmt.Fprintf(&b, "### Commit %d of %d (%s): %s\n(no changes left after filtering)\n\n", i+1, len(own), short, subject)
+			continue
+		}
+		diff, _ = prioritizeDiff(diff, maxCommitDiffBytes, cfg.TruncationNotice)
+		summary, err := summaryCompletion(fmt.Sprintf(`This is commit %d of %d of a large Pull Request, with the message:
+%s
+
+Summarise what its diff changes, file by file, as short bullet points. Mention new or changed functions, types, configuration and behaviour; skip formatting-only changes. Return only the bullet points.
+
+%s`, i+1, len(own), strings.TrimSpace(c.Commit.Message), diff))
+		if err != nil {
+			return "", fmt.Errorf("commit %s: %w", short, err)
+		}
+		fmt.Fprintf(&b, "### Commit %d of %d (%s): %s\n%s\n\n", i+1, len(own), short, subject, strings.TrimSpace(summary))
+	}
+
+	summaries := b.String()
+	for round := 1; len(summaries) > maxSize && round <= maxCondenseRounds; round++ {
+		log.Printf("Commit summaries take %d chars; condensing them (round %d/%d)...", len(summaries), round, maxCondenseRounds)
+		if summaries, err = summarizeChunks(textChunks(summaries, maxSize), "summary chunk", condenseSummaries); err != nil {
+			return "", err
+		}
+	}
+	return summaries, nil
+}
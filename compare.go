@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// modelOutput is one model's entry in a comparison comment.
+type modelOutput struct {
+	Model       string
+	Description string
+	Usage       tokenUsage
+	Err         error
+}
+
+// compareModels generates the description for in with each of models on the primary
+// provider, running every result through post so it matches what would be applied.
+func compareModels(cfg Config, in PromptInput, models []string, post func(string) string) []modelOutput {
+	creq := descriptionRequest(cfg, in)
+	creq.Messages = withPersona(redactMessages(creq.Messages))
+	var outputs []modelOutput
+	for _, model := range models {
+		log.Printf("Generating comparison description with %s...", model)
+		result, err := completeWithRetries(modelChain[0].generator, model, creq)
+		if err == nil && strings.TrimSpace(result.Content) == "" {
+			err = fmt.Errorf("empty response")
+		}
+		if err == nil && len(in.Sections) > 0 {
+			result.Content, err = renderStructured(result.Content, in.Template)
+		}
+		out := modelOutput{Model: model, Usage: result.Usage, Err: err}
+		if err != nil {
+			log.Printf("Warning: comparison model %s failed: %v", model, err)
+		} else {
+			out.Description = post(result.Content)
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs
+}
+
+// postModelComparison posts the outputs side by side as collapsible raw markdown blocks, so
+// maintainers can pick one and copy it into the PR body. Failed models get a short status
+// only: provider errors can quote response bodies and endpoint URLs, so they stay in the log.
+func postModelComparison(repo, prNum, token string, outputs []modelOutput) error {
+	var b strings.Builder
+	b.WriteString("### 🔍 DiffScribe — Model Comparison\n\nEach model below described this PR from the same prompt. Copy the one you prefer into the PR description.\n\n")
+	for _, out := range outputs {
+		if out.Err != nil {
+			fmt.Fprintf(&b, "<b>%s</b> — %s; see the workflow log for details.\n\n", out.Model, failureStatus(out.Err))
+			continue
+		}
+		stats := fmt.Sprintf("%d chars", len(out.Description))
+		if out.Usage.CompletionTokens > 0 {
+			stats += fmt.Sprintf(", %d completion tokens", out.Usage.CompletionTokens)
+		}
+		fence := codeFenceFor(out.Description)
+		fmt.Fprintf(&b, "<details><summary><b>%s</b> — %s</summary>\n\n%s\n%s\n%s\n\n</details>\n\n",
+			out.Model, stats, fence, strings.TrimRight(out.Description, "\n"), fence)
+	}
+	b.WriteString(commentFooter)
+	return postIssueComment(repo, prNum, token, b.String())
+}
+
+// failureStatus summarises a comparison model's error for the public comment, e.g.
+// "failed (HTTP 400)".
+func failureStatus(err error) string {
+	var statusErr *modelStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("failed (HTTP %d)", statusErr.Status)
+	case errors.Is(err, errStreamStalled):
+		return "failed (stream stalled)"
+	}
+	return "failed"
+}
+
+// postComparison describes the PR with each DIFFSCRIBE_COMPARE_MODELS model and posts the
+// outputs after the primary model's description, which came from the same prompt in.
+func postComparison(rc *runContext, in PromptInput, description string, post func(string) string) {
+	if len(rc.cfg.CompareModels) == 0 {
+		return
+	}
+	defer timings.Start("compare")()
+	outputs := append([]modelOutput{{Model: primaryModel, Description: description, Usage: rc.generation.Usage}},
+		compareModels(rc.cfg, in, rc.cfg.CompareModels, post)...)
+	if err := postModelComparison(rc.cfg.Repository, rc.cfg.PRNumber, rc.cfg.GitHubToken, outputs); err != nil {
+		log.Printf("Warning: failed to post the model comparison: %v", err)
+	}
+}
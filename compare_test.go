@@ -0,0 +1,23 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFailureStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: fmt.Errorf("attempt 3: %w", &modelStatusError{Provider: "OpenAI", Status: 400, Body: `{"error":"bad key sk-..."}`}), want: "failed (HTTP 400)"},
+		{err: errStreamStalled, want: "failed (stream stalled)"},
+		{err: errors.New(`Post "https://gateway.internal/v1": dial tcp: connection refused`), want: "failed"},
+	}
+	for _, tt := range tests {
+		if got := failureStatus(tt.err); got != tt.want {
+			t.Errorf("failureStatus(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,890 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHedgePhrases are the non-committal phrases that mark a section the model
+// could not actually fill from the diff.
+var defaultHedgePhrases = []string{
+	"cannot be determined",
+	"can't be determined",
+	"could not be determined",
+	"cannot be inferred",
+	"could not be inferred",
+	"not possible to determine",
+	"unable to determine",
+	"not enough information",
+	"insufficient information",
+	"not clear from the diff",
+	"not specified in the diff",
+}
+
+// Config holds the runtime settings read from the environment.
+type Config struct {
+	Repository string
+	PRNumber   string
+	PRBody     string
+
+	// GitHubToken authenticates REST API calls; ModelsToken authenticates model inference.
+	// Both fall back to GITHUB_TOKEN so least-privilege setups can split them.
+	GitHubToken string
+	ModelsToken string
+
+	// DisabledPath is the in-repo opt-out marker file (DIFFSCRIBE_DISABLED_PATH); set it to
+	// "none" to ignore opt-outs.
+	DisabledPath string
+
+	// EventName is the triggering event (GITHUB_EVENT_NAME) and EventPath its webhook payload
+	// file (GITHUB_EVENT_PATH); OnEvents lists the pull_request actions that trigger
+	// processing (DIFFSCRIBE_ON_EVENTS).
+	EventName string
+	EventPath string
+	OnEvents  []string
+
+	// SkipAuthors and OnlyAuthors filter PRs by author login (DIFFSCRIBE_SKIP_AUTHORS /
+	// DIFFSCRIBE_ONLY_AUTHORS); "[bot]" matches any bot account.
+	SkipAuthors []string
+	OnlyAuthors []string
+
+	// WIPPrefixes are title prefixes that mark a PR as work in progress (DIFFSCRIBE_WIP_PREFIXES,
+	// "none" to disable); WIPAction is "skip" or "remind" (DIFFSCRIBE_WIP_ACTION).
+	WIPPrefixes []string
+	WIPAction   string
+
+	// MaxFiles and MaxChangedLines skip PRs too large to describe reliably, asking the author
+	// to describe them instead (DIFFSCRIBE_MAX_FILES, DIFFSCRIBE_MAX_CHANGED_LINES; 0 disables).
+	MaxFiles        int
+	MaxChangedLines int
+
+	// BaseRef and HeadRef restrict the description to the compare diff between two refs
+	// (DIFFSCRIBE_BASE_REF / DIFFSCRIBE_HEAD_REF), e.g. the commits since a prior review.
+	BaseRef string
+	HeadRef string
+
+	// MergeDiff selects how the PR diff is obtained when no range is configured: "pr" (the PR
+	// diff endpoint), "three-dot" or "two-dot" (compare of the PR's base and head commits), or
+	// "auto" (three-dot only when the branch has merge commits) (DIFFSCRIBE_MERGE_DIFF).
+	MergeDiff string
+
+	// DiffSource selects how the PR diff itself is fetched: "diff" (the diff endpoint),
+	// "files" (the paginated files API, assembled into a diff) or "auto" (the files API when
+	// the diff endpoint fails, e.g. for PRs too large for GitHub to render)
+	// (DIFFSCRIBE_DIFF_SOURCE).
+	DiffSource string
+
+	// HedgePhrases are matched case-insensitively against generated lines (DIFFSCRIBE_HEDGE_PHRASES).
+	HedgePhrases []string
+
+	// SummarizeMoves lists renamed and moved files in the prompt instead of passing their
+	// near-duplicate content (DIFFSCRIBE_SUMMARIZE_MOVES).
+	SummarizeMoves bool
+
+	// Symbols adds the functions, types and methods the diff changes, for supported languages,
+	// to the prompt (DIFFSCRIBE_SYMBOLS); SymbolsSection also appends them to the description
+	// under "## Changed symbols" (DIFFSCRIBE_SYMBOLS_SECTION).
+	Symbols        bool
+	SymbolsSection bool
+
+	// GoAPIDelta parses the base and head versions of the changed Go files and adds the
+	// exported identifiers they add, remove or change to the prompt (DIFFSCRIBE_GO_API_DELTA);
+	// GoAPISection also appends them to the description under "## API changes"
+	// (DIFFSCRIBE_GO_API_SECTION).
+	GoAPIDelta   bool
+	GoAPISection bool
+
+	// FileTree adds a tree of all changed files with their line counts to the prompt, so the
+	// model sees the PR's shape even when the diff is truncated (DIFFSCRIBE_FILE_TREE).
+	FileTree bool
+
+	// ClassifyFiles adds the source, test and documentation file counts and the changed test
+	// files to the prompt, so the description states what test coverage changed
+	// (DIFFSCRIBE_CLASSIFY_FILES); TestChangesSection also appends the test files to the
+	// description under "## Test changes" (DIFFSCRIBE_TEST_CHANGES_SECTION).
+	ClassifyFiles      bool
+	TestChangesSection bool
+
+	// CommitMessages adds the PR's commit messages to the prompt (DIFFSCRIBE_COMMIT_MESSAGES),
+	// capped at CommitBudget bytes (DIFFSCRIBE_COMMIT_BUDGET).
+	CommitMessages bool
+	CommitBudget   int
+
+	// BodyBudget caps how many bytes of the current PR body go into the prompt (DIFFSCRIBE_BODY_BUDGET).
+	BodyBudget int
+
+	// MaxSectionWords caps each generated section's length (DIFFSCRIBE_MAX_SECTION_WORDS, 0 = no cap).
+	MaxSectionWords int
+
+	// HeadingSynonyms maps renamed headings back to template sections (DIFFSCRIBE_HEADING_SYNONYMS,
+	// e.g. "Overview=Summary,Why=Motivation / Context").
+	HeadingSynonyms map[string]string
+
+	// DependencyChanges appends a "## Dependency changes" list derived from changed manifests
+	// (DIFFSCRIBE_DEPENDENCY_CHANGES).
+	DependencyChanges bool
+
+	// FileTable adds a table of changed files with line counts and change types
+	// (DIFFSCRIBE_FILE_TABLE), inside the FileTableSection section when the description has
+	// one (DIFFSCRIBE_FILE_TABLE_SECTION), otherwise under "## Changed files".
+	FileTable        bool
+	FileTableSection string
+
+	// FileSummaries appends a "## Changes by file" list with a model-written line per changed
+	// file (DIFFSCRIBE_FILE_SUMMARIES).
+	FileSummaries bool
+
+	// Monorepo groups the diff by package, has the model summarise each package, adds the
+	// summaries to the prompt and appends them under "## Changes by package"
+	// (DIFFSCRIBE_MONOREPO). Packages are the directories matching MonorepoRoots
+	// (DIFFSCRIBE_MONOREPO_ROOTS), else the workspace packages the repository declares, else
+	// the top-level directories.
+	Monorepo      bool
+	MonorepoRoots []string
+
+	// KeepAuthorSections keeps sections the author already filled instead of the generated
+	// text (DIFFSCRIBE_KEEP_AUTHOR_SECTIONS).
+	KeepAuthorSections bool
+
+	// ReleaseNote appends a one-line, user-facing release note (DIFFSCRIBE_RELEASE_NOTE) as a
+	// "## Release note" section or, with ReleaseNoteFormat "block", a release-note code block
+	// (DIFFSCRIBE_RELEASE_NOTE_FORMAT).
+	ReleaseNote       bool
+	ReleaseNoteFormat string
+
+	// DeterministicFallback writes a file list and line counts into the summary section when
+	// the model fails or the diff is empty (DIFFSCRIBE_DETERMINISTIC_FALLBACK).
+	DeterministicFallback bool
+
+	// ReviewChecklistPath points to a markdown snippet appended as "## Reviewer checklist" (DIFFSCRIBE_REVIEW_CHECKLIST).
+	ReviewChecklistPath string
+
+	// RPM caps outbound requests per minute across all APIs (DIFFSCRIBE_RPM, 0 = unlimited),
+	// allowing bursts of up to RPMBurst requests (DIFFSCRIBE_RPM_BURST).
+	RPM      int
+	RPMBurst int
+
+	// RateLimitRetries is how often a request rejected by a GitHub rate limit (429 or the 403
+	// secondary rate limit) is retried after backing off (DIFFSCRIBE_RATE_LIMIT_RETRIES).
+	RateLimitRetries int
+
+	// MaxFileDiffBytes replaces any single file's diff larger than this with a one-line note
+	// (DIFFSCRIBE_MAX_FILE_DIFF_BYTES, 0 = no cap).
+	MaxFileDiffBytes int
+
+	// RedactPaths are gitignore-style globs of files whose diff content is never sent to the
+	// model (DIFFSCRIBE_REDACT_PATHS).
+	RedactPaths []string
+
+	// IgnoreFile lists gitignore-style globs of files left out of the diff sent to the model
+	// (DIFFSCRIBE_IGNORE_FILE, default .diffscribeignore).
+	IgnoreFile string
+
+	// CollapseLockfiles reduces dependency lockfile diffs to a one-line summary
+	// (DIFFSCRIBE_COLLAPSE_LOCKFILES).
+	CollapseLockfiles bool
+
+	// SkipLinguist strips files marked linguist-generated or linguist-vendored in
+	// .gitattributes from the diff before prompting (DIFFSCRIBE_SKIP_LINGUIST).
+	SkipLinguist bool
+
+	// TruncateStrategy selects how an oversized diff is reduced: head, head-tail, prioritize
+	// or map-reduce (DIFFSCRIBE_TRUNCATE_STRATEGY, default head).
+	TruncateStrategy string
+
+	// CommitSummaryLines is the changed-line count above which each commit's diff is
+	// summarised separately and the description is written from those summaries instead of
+	// the reduced diff (DIFFSCRIBE_COMMIT_SUMMARY_LINES, 0 to disable).
+	CommitSummaryLines int
+
+	// TruncationNotice is appended to diffs cut to fit the context window (DIFFSCRIBE_TRUNCATION_NOTICE).
+	TruncationNotice string
+
+	// NetDiffNote tells the model the diff only shows net changes (DIFFSCRIBE_NET_DIFF_NOTE);
+	// CountReverts adds the number of revert commits to that note (DIFFSCRIBE_COUNT_REVERTS).
+	NetDiffNote  bool
+	CountReverts bool
+
+	// UseMilestone adds the PR's milestone title and description to the prompt
+	// (DIFFSCRIBE_USE_MILESTONE).
+	UseMilestone bool
+
+	// RepoContext adds the README's first section and the CONTRIBUTING guide's conventions to
+	// the prompt, so descriptions use the project's terminology (DIFFSCRIBE_REPO_CONTEXT).
+	RepoContext bool
+
+	// LinkedIssues adds the title and body of the issues the PR body or branch name
+	// references to the prompt (DIFFSCRIBE_LINKED_ISSUES). Jira keys such as "ABC-456" are
+	// looked up when JiraURL is set (DIFFSCRIBE_JIRA_URL), authenticating with JiraToken
+	// (DIFFSCRIBE_JIRA_TOKEN) and, for basic auth, JiraUser (DIFFSCRIBE_JIRA_USER).
+	// References to other repositories are only followed with CrossRepoIssues
+	// (DIFFSCRIBE_CROSS_REPO_ISSUES), since the token may read private repositories whose
+	// issue text would then reach the description.
+	LinkedIssues    bool
+	CrossRepoIssues bool
+	JiraURL         string
+	JiraUser        string
+	JiraToken       string
+
+	// StackRefs resolves "Depends on #N" / "Stacked on #N" references in the PR body and lists
+	// them in the completion comment (DIFFSCRIBE_STACK_REFS).
+	StackRefs bool
+
+	// StackContext detects when the PR's base branch is the head of another open PR, adds that
+	// parent PR's title and description to the prompt and notes the stack in the description
+	// (DIFFSCRIBE_STACK_CONTEXT).
+	StackContext bool
+
+	// SuggestReviewers adds CODEOWNERS of the changed files to the completion comment
+	// (DIFFSCRIBE_SUGGEST_REVIEWERS).
+	SuggestReviewers bool
+
+	// CommentSummary adds a 2–3 sentence summary of the change to the completion comment
+	// (DIFFSCRIBE_COMMENT_SUMMARY).
+	CommentSummary bool
+
+	// BlameReviewers adds the accounts that most recently changed the touched files to the
+	// completion comment (DIFFSCRIBE_BLAME_REVIEWERS), looking at no more than BlameMaxFiles
+	// files (DIFFSCRIBE_BLAME_MAX_FILES).
+	BlameReviewers bool
+	BlameMaxFiles  int
+
+	// QualityScore has the model rate the final description and adds the score to the
+	// completion comment (DIFFSCRIBE_QUALITY_SCORE).
+	QualityScore bool
+
+	// ZeroFillComment controls the completion comment when no section was filled:
+	// "notice" posts a "not enough information" message, "skip" posts nothing
+	// (DIFFSCRIBE_ZERO_FILL_COMMENT).
+	ZeroFillComment string
+
+	// Onboarding adds a one-time introduction to the first notice comment in a repository
+	// (DIFFSCRIBE_ONBOARDING); OnboardingLabel is the repository label that records it was
+	// posted (DIFFSCRIBE_ONBOARDING_LABEL).
+	Onboarding      bool
+	OnboardingLabel string
+
+	// SquashMessage posts a suggested squash-merge commit message as a comment
+	// (DIFFSCRIBE_SQUASH_MESSAGE).
+	SquashMessage bool
+
+	// React adds a Reaction (e.g. "rocket") to the PR once it has been processed
+	// (DIFFSCRIBE_REACT / DIFFSCRIBE_REACTION).
+	React    bool
+	Reaction string
+
+	// SafeMode never edits PR bodies, whatever the outputs say: the body target is dropped
+	// (falling back to the comment target) and body updates are refused (DIFFSCRIBE_SAFE_MODE).
+	SafeMode bool
+
+	// RedactInput masks credentials (private keys, cloud and API tokens, password
+	// assignments, high-entropy strings) in everything sent to a model
+	// (DIFFSCRIBE_REDACT_INPUT).
+	RedactInput bool
+
+	// ScrubPII masks email addresses, phone numbers and matches of PIIPatterns
+	// (DIFFSCRIBE_PII_PATTERNS, one regular expression per line) in the diff before it is
+	// put in a prompt (DIFFSCRIBE_SCRUB_PII).
+	ScrubPII    bool
+	PIIPatterns []*regexp.Regexp
+
+	// PostProcessors lists the passes applied to the generated description, in order
+	// (DIFFSCRIBE_POST_PROCESSORS).
+	PostProcessors []string
+
+	// PromptFile and SystemPromptFile override the built-in user prompt (a text/template with
+	// {{.Template}}, {{.Body}}, {{.Diff}}, ...) and system prompt when they exist
+	// (DIFFSCRIBE_PROMPT_FILE / DIFFSCRIBE_SYSTEM_PROMPT_FILE).
+	PromptFile       string
+	SystemPromptFile string
+
+	// SystemPrompt is added to the system message of every generation, e.g. writing
+	// standards such as "use British English" (DIFFSCRIBE_SYSTEM_PROMPT).
+	SystemPrompt string
+
+	// DefaultTemplate is the template used when the repository's PR template is empty
+	// (DIFFSCRIBE_DEFAULT_TEMPLATE); without it a built-in template is used.
+	DefaultTemplate string
+
+	// Cooldown skips a PR DiffScribe already ran on within this interval, going by the run
+	// marker in its notice comment (DIFFSCRIBE_COOLDOWN, 0 = no cooldown).
+	Cooldown time.Duration
+
+	// Outputs lists where the description is published, in order (DIFFSCRIBE_OUTPUTS).
+	// DIFFSCRIBE_CHECK_RUN=true is shorthand for adding the checkrun target.
+	Outputs []OutputTarget
+
+	// Cache selects where generated descriptions are cached: "fs" (CacheDir), "redis"
+	// (RedisURL, entries expiring after CacheTTL) or "none" (DIFFSCRIBE_CACHE,
+	// DIFFSCRIBE_CACHE_DIR, DIFFSCRIBE_REDIS_URL, DIFFSCRIBE_CACHE_TTL).
+	Cache    string
+	CacheDir string
+	RedisURL string
+	CacheTTL time.Duration
+
+	// Provider is the inference backend (DIFFSCRIBE_PROVIDER): github-models (ModelsToken,
+	// DIFFSCRIBE_MODELS_TOKEN or GITHUB_TOKEN), openai (OpenAIKey, OPENAI_API_KEY),
+	// anthropic (AnthropicKey, ANTHROPIC_API_KEY), azure-openai (AZURE_OPENAI_ENDPOINT,
+	// AZURE_OPENAI_API_KEY, DIFFSCRIBE_AZURE_DEPLOYMENT), bedrock (AWS_REGION and the AWS
+	// credential chain), gemini (GEMINI_API_KEY), vertex (GOOGLE_APPLICATION_CREDENTIALS),
+	// openai-compatible (DIFFSCRIBE_BASE_URL, optionally DIFFSCRIBE_API_KEY) or ollama
+	// (DIFFSCRIBE_OLLAMA_URL, optionally DIFFSCRIBE_OLLAMA_API_KEY). Each backend's settings
+	// are documented below.
+	Provider     string
+	OpenAIKey    string
+	AnthropicKey string
+
+	// CompareModels are extra models on the primary provider whose descriptions are posted
+	// next to the primary model's in a collapsible comparison comment (DIFFSCRIBE_COMPARE_MODELS).
+	CompareModels []string
+
+	// MaxTokens, Temperature and TopP are the sampling parameters of the description call
+	// (DIFFSCRIBE_MAX_TOKENS, default 2000 or 8000 for reasoning models; DIFFSCRIBE_TEMPERATURE,
+	// default 0.3; DIFFSCRIBE_TOP_P, default unset). DIFFSCRIBE_EXTRA_PARAMS still overrides
+	// them.
+	MaxTokens   int
+	Temperature float64
+	TopP        float64
+
+	// MaxAttempts is how often each model is tried on 5xx and stalled-stream failures, with
+	// jittered exponential backoff honouring Retry-After (DIFFSCRIBE_MAX_ATTEMPTS).
+	MaxAttempts int
+
+	// InputTokens is the prompt token budget the diff is trimmed to fit (DIFFSCRIBE_INPUT_TOKENS,
+	// 0 = derived from the provider and model).
+	InputTokens int
+
+	// Stream requests streamed completions from OpenAI-style providers, logging progress and
+	// aborting a stream idle for StreamIdleTimeout (DIFFSCRIBE_STREAM /
+	// DIFFSCRIBE_STREAM_IDLE_TIMEOUT).
+	Stream            bool
+	StreamIdleTimeout time.Duration
+
+	// Models overrides the provider's model, e.g. gpt-4o or o3-mini; several models form a
+	// fallback chain tried in order (DIFFSCRIBE_MODEL).
+	Models []string
+
+	// AzureEndpoint, AzureKey, AzureDeployment and AzureAPIVersion address an Azure OpenAI
+	// deployment (AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY, DIFFSCRIBE_AZURE_DEPLOYMENT,
+	// DIFFSCRIBE_AZURE_API_VERSION).
+	AzureEndpoint   string
+	AzureKey        string
+	AzureDeployment string
+	AzureAPIVersion string
+
+	// BaseURL and APIKey address the openai-compatible provider, any server implementing
+	// OpenAI's /chat/completions (DIFFSCRIBE_BASE_URL / DIFFSCRIBE_API_KEY, optional).
+	BaseURL string
+	APIKey  string
+
+	// OllamaURL and OllamaModel point the ollama provider at a local OpenAI-compatible server
+	// such as Ollama or llama.cpp (DIFFSCRIBE_OLLAMA_URL / DIFFSCRIBE_OLLAMA_MODEL); OllamaKey
+	// is an optional bearer token for servers behind a proxy (DIFFSCRIBE_OLLAMA_API_KEY).
+	OllamaURL   string
+	OllamaModel string
+	OllamaKey   string
+
+	// AWSRegion and BedrockModel select the Amazon Bedrock region and model ID for the bedrock
+	// provider (AWS_REGION or AWS_DEFAULT_REGION / DIFFSCRIBE_BEDROCK_MODEL); credentials come
+	// from the standard AWS environment variables or shared credentials file.
+	AWSRegion    string
+	BedrockModel string
+
+	// GeminiModel is the model for the gemini provider (API key GeminiKey, GEMINI_API_KEY) and
+	// the vertex provider (a service account key file GoogleCredentials,
+	// GOOGLE_APPLICATION_CREDENTIALS, in VertexProject and VertexLocation); GeminiSafety maps
+	// harm categories to block thresholds for both (DIFFSCRIBE_GEMINI_MODEL,
+	// DIFFSCRIBE_VERTEX_PROJECT, DIFFSCRIBE_VERTEX_LOCATION, DIFFSCRIBE_GEMINI_SAFETY).
+	GeminiModel       string
+	GeminiKey         string
+	GoogleCredentials string
+	VertexProject     string
+	VertexLocation    string
+	GeminiSafety      map[string]string
+
+	// FallbackModel is tried after the DIFFSCRIBE_MODEL chain fails
+	// (DIFFSCRIBE_FALLBACK_MODEL); FallbackProvider names its provider
+	// (DIFFSCRIBE_FALLBACK_PROVIDER, one of the generators).
+	FallbackModel    string
+	FallbackProvider string
+
+	// ExtraParams are extra request body fields (top_p, presence_penalty, ...) for the
+	// description call, given as a JSON object (DIFFSCRIBE_EXTRA_PARAMS). They may override
+	// max_tokens and temperature but never model or messages.
+	ExtraParams map[string]any
+
+	// MergeSystem folds the system prompt into the first user message (DIFFSCRIBE_MERGE_SYSTEM);
+	// SystemRole and UserRole rename the chat roles (DIFFSCRIBE_SYSTEM_ROLE / DIFFSCRIBE_USER_ROLE)
+	// for gateways that expect different names.
+	MergeSystem bool
+	SystemRole  string
+	UserRole    string
+
+	// StructuredOutput asks the model for the template sections as a JSON object, validated
+	// against the template headings and rendered locally (DIFFSCRIBE_STRUCTURED_OUTPUT).
+	StructuredOutput bool
+
+	// Debug enables verbose diagnostics such as the diff-to-section mapping (DIFFSCRIBE_DEBUG).
+	Debug bool
+
+	// MetricsFile receives a JSON summary of the run when set (DIFFSCRIBE_METRICS_FILE).
+	MetricsFile string
+
+	// UsageFooter appends the run's token usage and estimated cost to the completion comment
+	// (DIFFSCRIBE_USAGE_FOOTER).
+	UsageFooter bool
+
+	// ModelPrices override the built-in model prices used for cost estimates, in USD per
+	// million prompt and completion tokens (DIFFSCRIBE_MODEL_PRICES).
+	ModelPrices map[string]modelPrice
+}
+
+// loadConfig reads the configuration from environment variables and validates it.
+func loadConfig() (Config, error) {
+	cfg := Config{
+		Repository:   os.Getenv("GITHUB_REPOSITORY"),
+		PRNumber:     os.Getenv("PR_NUMBER"),
+		PRBody:       os.Getenv("PR_BODY"),
+		DisabledPath: envString("DIFFSCRIBE_DISABLED_PATH", ".github/diffscribe/disabled"),
+		EventName:    os.Getenv("GITHUB_EVENT_NAME"),
+		EventPath:    os.Getenv("GITHUB_EVENT_PATH"),
+		OnEvents:     envList("DIFFSCRIBE_ON_EVENTS", defaultOnEvents),
+		SkipAuthors:  envList("DIFFSCRIBE_SKIP_AUTHORS", nil),
+		OnlyAuthors:  envList("DIFFSCRIBE_ONLY_AUTHORS", nil),
+		WIPPrefixes:  envList("DIFFSCRIBE_WIP_PREFIXES", defaultWIPPrefixes),
+		WIPAction:    envString("DIFFSCRIBE_WIP_ACTION", "skip"),
+		BaseRef:      envString("DIFFSCRIBE_BASE_REF", ""),
+		HeadRef:      envString("DIFFSCRIBE_HEAD_REF", ""),
+		MergeDiff:    envString("DIFFSCRIBE_MERGE_DIFF", "pr"),
+		DiffSource:   envString("DIFFSCRIBE_DIFF_SOURCE", "auto"),
+		HedgePhrases: envList("DIFFSCRIBE_HEDGE_PHRASES", defaultHedgePhrases),
+		RedactPaths:  envList("DIFFSCRIBE_REDACT_PATHS", nil),
+
+		ReviewChecklistPath: envString("DIFFSCRIBE_REVIEW_CHECKLIST", ""),
+		ReleaseNoteFormat:   envString("DIFFSCRIBE_RELEASE_NOTE_FORMAT", "section"),
+		FileTableSection:    envString("DIFFSCRIBE_FILE_TABLE_SECTION", ""),
+		PromptFile:          envString("DIFFSCRIBE_PROMPT_FILE", ".github/diffscribe/prompt.md"),
+		SystemPromptFile:    envString("DIFFSCRIBE_SYSTEM_PROMPT_FILE", ".github/diffscribe/system.md"),
+		SystemPrompt:        envString("DIFFSCRIBE_SYSTEM_PROMPT", ""),
+		DefaultTemplate:     envString("DIFFSCRIBE_DEFAULT_TEMPLATE", ""),
+		TruncationNotice:    envString("DIFFSCRIBE_TRUNCATION_NOTICE", defaultTruncationNotice),
+		TruncateStrategy:    envString("DIFFSCRIBE_TRUNCATE_STRATEGY", strategyHead),
+		ZeroFillComment:     envString("DIFFSCRIBE_ZERO_FILL_COMMENT", "notice"),
+		Cache:               envString("DIFFSCRIBE_CACHE", "fs"),
+		CacheDir:            envString("DIFFSCRIBE_CACHE_DIR", defaultCacheDir()),
+		RedisURL:            envString("DIFFSCRIBE_REDIS_URL", ""),
+		MetricsFile:         envString("DIFFSCRIBE_METRICS_FILE", ""),
+		IgnoreFile:          envString("DIFFSCRIBE_IGNORE_FILE", ".diffscribeignore"),
+		OnboardingLabel:     envString("DIFFSCRIBE_ONBOARDING_LABEL", "diffscribe"),
+		Reaction:            envString("DIFFSCRIBE_REACTION", "rocket"),
+		FallbackModel:       envString("DIFFSCRIBE_FALLBACK_MODEL", ""),
+		Provider:            envString("DIFFSCRIBE_PROVIDER", defaultProvider),
+		OpenAIKey:           os.Getenv("OPENAI_API_KEY"),
+		AnthropicKey:        os.Getenv("ANTHROPIC_API_KEY"),
+		Models:              envList("DIFFSCRIBE_MODEL", nil),
+		CompareModels:       envList("DIFFSCRIBE_COMPARE_MODELS", nil),
+		AzureEndpoint:       os.Getenv("AZURE_OPENAI_ENDPOINT"),
+		AzureKey:            os.Getenv("AZURE_OPENAI_API_KEY"),
+		AzureDeployment:     envString("DIFFSCRIBE_AZURE_DEPLOYMENT", ""),
+		AzureAPIVersion:     envString("DIFFSCRIBE_AZURE_API_VERSION", "2024-10-21"),
+		BaseURL:             envString("DIFFSCRIBE_BASE_URL", ""),
+		APIKey:              os.Getenv("DIFFSCRIBE_API_KEY"),
+		OllamaURL:           envString("DIFFSCRIBE_OLLAMA_URL", "http://localhost:11434/v1"),
+		OllamaModel:         envString("DIFFSCRIBE_OLLAMA_MODEL", "llama3.1"),
+		OllamaKey:           os.Getenv("DIFFSCRIBE_OLLAMA_API_KEY"),
+		AWSRegion:           envString("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION")),
+		BedrockModel:        envString("DIFFSCRIBE_BEDROCK_MODEL", "anthropic.claude-3-5-haiku-20241022-v1:0"),
+		GeminiModel:         envString("DIFFSCRIBE_GEMINI_MODEL", "gemini-1.5-flash"),
+		GeminiKey:           os.Getenv("GEMINI_API_KEY"),
+		GoogleCredentials:   os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"),
+		VertexProject:       envString("DIFFSCRIBE_VERTEX_PROJECT", ""),
+		VertexLocation:      envString("DIFFSCRIBE_VERTEX_LOCATION", "us-central1"),
+		FallbackProvider:    envString("DIFFSCRIBE_FALLBACK_PROVIDER", ""),
+		SystemRole:          envString("DIFFSCRIBE_SYSTEM_ROLE", "system"),
+		UserRole:            envString("DIFFSCRIBE_USER_ROLE", "user"),
+	}
+
+	var err error
+	if cfg.MaxFileDiffBytes, err = envInt("DIFFSCRIBE_MAX_FILE_DIFF_BYTES", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxFiles, err = envInt("DIFFSCRIBE_MAX_FILES", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxChangedLines, err = envInt("DIFFSCRIBE_MAX_CHANGED_LINES", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.CommitSummaryLines, err = envInt("DIFFSCRIBE_COMMIT_SUMMARY_LINES", 0); err != nil {
+		return cfg, err
+	}
+	model := providerModel(cfg)
+	if len(cfg.Models) > 0 {
+		model = cfg.Models[0]
+	}
+	if cfg.MaxTokens, err = envInt("DIFFSCRIBE_MAX_TOKENS", defaultMaxTokens(model)); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxTokens < 1 {
+		return cfg, fmt.Errorf("DIFFSCRIBE_MAX_TOKENS must be at least 1, got %d", cfg.MaxTokens)
+	}
+	if cfg.Temperature, err = envFloat("DIFFSCRIBE_TEMPERATURE", 0.3); err != nil {
+		return cfg, err
+	}
+	if cfg.Temperature < 0 || cfg.Temperature > 2 {
+		return cfg, fmt.Errorf("DIFFSCRIBE_TEMPERATURE must be between 0 and 2, got %g", cfg.Temperature)
+	}
+	if cfg.TopP, err = envFloat("DIFFSCRIBE_TOP_P", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.TopP < 0 || cfg.TopP > 1 {
+		return cfg, fmt.Errorf("DIFFSCRIBE_TOP_P must be between 0 and 1, got %g", cfg.TopP)
+	}
+	if cfg.MaxAttempts, err = envInt("DIFFSCRIBE_MAX_ATTEMPTS", 3); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxAttempts < 1 {
+		return cfg, fmt.Errorf("DIFFSCRIBE_MAX_ATTEMPTS must be at least 1, got %d", cfg.MaxAttempts)
+	}
+	if cfg.InputTokens, err = envInt("DIFFSCRIBE_INPUT_TOKENS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.Stream, err = envBool("DIFFSCRIBE_STREAM", false); err != nil {
+		return cfg, err
+	}
+	if cfg.StreamIdleTimeout, err = envDuration("DIFFSCRIBE_STREAM_IDLE_TIMEOUT", 30*time.Second); err != nil {
+		return cfg, err
+	}
+	if cfg.GeminiSafety, err = parseGeminiSafety(envList("DIFFSCRIBE_GEMINI_SAFETY", nil)); err != nil {
+		return cfg, err
+	}
+	if cfg.HeadingSynonyms, err = parseHeadingSynonyms(envList("DIFFSCRIBE_HEADING_SYNONYMS", nil)); err != nil {
+		return cfg, err
+	}
+	if cfg.SummarizeMoves, err = envBool("DIFFSCRIBE_SUMMARIZE_MOVES", true); err != nil {
+		return cfg, err
+	}
+	if cfg.Symbols, err = envBool("DIFFSCRIBE_SYMBOLS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.SymbolsSection, err = envBool("DIFFSCRIBE_SYMBOLS_SECTION", false); err != nil {
+		return cfg, err
+	}
+	if cfg.GoAPIDelta, err = envBool("DIFFSCRIBE_GO_API_DELTA", false); err != nil {
+		return cfg, err
+	}
+	if cfg.GoAPISection, err = envBool("DIFFSCRIBE_GO_API_SECTION", false); err != nil {
+		return cfg, err
+	}
+	if cfg.FileTree, err = envBool("DIFFSCRIBE_FILE_TREE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.ClassifyFiles, err = envBool("DIFFSCRIBE_CLASSIFY_FILES", false); err != nil {
+		return cfg, err
+	}
+	if cfg.TestChangesSection, err = envBool("DIFFSCRIBE_TEST_CHANGES_SECTION", false); err != nil {
+		return cfg, err
+	}
+	if cfg.CommitMessages, err = envBool("DIFFSCRIBE_COMMIT_MESSAGES", false); err != nil {
+		return cfg, err
+	}
+	if cfg.CommitBudget, err = envInt("DIFFSCRIBE_COMMIT_BUDGET", defaultCommitBudget); err != nil {
+		return cfg, err
+	}
+	if cfg.BodyBudget, err = envInt("DIFFSCRIBE_BODY_BUDGET", defaultBodyBudget); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxSectionWords, err = envInt("DIFFSCRIBE_MAX_SECTION_WORDS", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.NetDiffNote, err = envBool("DIFFSCRIBE_NET_DIFF_NOTE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.CountReverts, err = envBool("DIFFSCRIBE_COUNT_REVERTS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.FileTable, err = envBool("DIFFSCRIBE_FILE_TABLE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.FileSummaries, err = envBool("DIFFSCRIBE_FILE_SUMMARIES", false); err != nil {
+		return cfg, err
+	}
+	if cfg.Monorepo, err = envBool("DIFFSCRIBE_MONOREPO", false); err != nil {
+		return cfg, err
+	}
+	cfg.MonorepoRoots = envList("DIFFSCRIBE_MONOREPO_ROOTS", nil)
+	if cfg.SkipLinguist, err = envBool("DIFFSCRIBE_SKIP_LINGUIST", true); err != nil {
+		return cfg, err
+	}
+	if cfg.CollapseLockfiles, err = envBool("DIFFSCRIBE_COLLAPSE_LOCKFILES", true); err != nil {
+		return cfg, err
+	}
+	if cfg.KeepAuthorSections, err = envBool("DIFFSCRIBE_KEEP_AUTHOR_SECTIONS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.DependencyChanges, err = envBool("DIFFSCRIBE_DEPENDENCY_CHANGES", false); err != nil {
+		return cfg, err
+	}
+	if cfg.DeterministicFallback, err = envBool("DIFFSCRIBE_DETERMINISTIC_FALLBACK", false); err != nil {
+		return cfg, err
+	}
+	if cfg.ReleaseNote, err = envBool("DIFFSCRIBE_RELEASE_NOTE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.ReleaseNoteFormat != "section" && cfg.ReleaseNoteFormat != "block" {
+		return cfg, fmt.Errorf("DIFFSCRIBE_RELEASE_NOTE_FORMAT must be section or block, got %q", cfg.ReleaseNoteFormat)
+	}
+	if cfg.UseMilestone, err = envBool("DIFFSCRIBE_USE_MILESTONE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.RepoContext, err = envBool("DIFFSCRIBE_REPO_CONTEXT", false); err != nil {
+		return cfg, err
+	}
+	if cfg.LinkedIssues, err = envBool("DIFFSCRIBE_LINKED_ISSUES", false); err != nil {
+		return cfg, err
+	}
+	if cfg.CrossRepoIssues, err = envBool("DIFFSCRIBE_CROSS_REPO_ISSUES", false); err != nil {
+		return cfg, err
+	}
+	cfg.JiraURL = envString("DIFFSCRIBE_JIRA_URL", "")
+	cfg.JiraUser = envString("DIFFSCRIBE_JIRA_USER", "")
+	cfg.JiraToken = envString("DIFFSCRIBE_JIRA_TOKEN", "")
+	if cfg.StackRefs, err = envBool("DIFFSCRIBE_STACK_REFS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.StackContext, err = envBool("DIFFSCRIBE_STACK_CONTEXT", false); err != nil {
+		return cfg, err
+	}
+	if cfg.SuggestReviewers, err = envBool("DIFFSCRIBE_SUGGEST_REVIEWERS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.CommentSummary, err = envBool("DIFFSCRIBE_COMMENT_SUMMARY", false); err != nil {
+		return cfg, err
+	}
+	if cfg.BlameReviewers, err = envBool("DIFFSCRIBE_BLAME_REVIEWERS", false); err != nil {
+		return cfg, err
+	}
+	if cfg.BlameMaxFiles, err = envInt("DIFFSCRIBE_BLAME_MAX_FILES", 10); err != nil {
+		return cfg, err
+	}
+	if cfg.QualityScore, err = envBool("DIFFSCRIBE_QUALITY_SCORE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.Onboarding, err = envBool("DIFFSCRIBE_ONBOARDING", false); err != nil {
+		return cfg, err
+	}
+	if cfg.SquashMessage, err = envBool("DIFFSCRIBE_SQUASH_MESSAGE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.React, err = envBool("DIFFSCRIBE_REACT", false); err != nil {
+		return cfg, err
+	}
+	if !slices.Contains(reactionContents, cfg.Reaction) {
+		return cfg, fmt.Errorf("DIFFSCRIBE_REACTION must be one of %s, got %q", strings.Join(reactionContents, ", "), cfg.Reaction)
+	}
+	if cfg.ZeroFillComment != "notice" && cfg.ZeroFillComment != "skip" {
+		return cfg, fmt.Errorf("DIFFSCRIBE_ZERO_FILL_COMMENT must be notice or skip, got %q", cfg.ZeroFillComment)
+	}
+	if !slices.Contains([]string{"pr", "auto", "three-dot", "two-dot"}, cfg.MergeDiff) {
+		return cfg, fmt.Errorf("DIFFSCRIBE_MERGE_DIFF must be pr, auto, three-dot or two-dot, got %q", cfg.MergeDiff)
+	}
+	if !slices.Contains([]string{"diff", "files", "auto"}, cfg.DiffSource) {
+		return cfg, fmt.Errorf("DIFFSCRIBE_DIFF_SOURCE must be diff, files or auto, got %q", cfg.DiffSource)
+	}
+	if !slices.Contains(truncateStrategies, cfg.TruncateStrategy) {
+		return cfg, fmt.Errorf("DIFFSCRIBE_TRUNCATE_STRATEGY must be one of %s, got %q", strings.Join(truncateStrategies, ", "), cfg.TruncateStrategy)
+	}
+	if _, ok := generators[cfg.Provider]; !ok {
+		return cfg, fmt.Errorf("DIFFSCRIBE_PROVIDER %q is not supported", cfg.Provider)
+	}
+	if cfg.FallbackProvider == "" {
+		cfg.FallbackProvider = cfg.Provider
+	}
+	if _, ok := generators[cfg.FallbackProvider]; !ok {
+		return cfg, fmt.Errorf("DIFFSCRIBE_FALLBACK_PROVIDER %q is not supported", cfg.FallbackProvider)
+	}
+	if cfg.PostProcessors, err = parsePostProcessors(envList("DIFFSCRIBE_POST_PROCESSORS", defaultPostProcessors)); err != nil {
+		return cfg, err
+	}
+	if cfg.Outputs, err = parseOutputTargets(envList("DIFFSCRIBE_OUTPUTS", defaultOutputs)); err != nil {
+		return cfg, err
+	}
+	checkRun, err := envBool("DIFFSCRIBE_CHECK_RUN", false)
+	if err != nil {
+		return cfg, err
+	}
+	if checkRun && !hasOutputTarget(cfg.Outputs, OutputCheckRun) {
+		cfg.Outputs = append(cfg.Outputs, OutputCheckRun)
+	}
+	if cfg.SafeMode, err = envBool("DIFFSCRIBE_SAFE_MODE", false); err != nil {
+		return cfg, err
+	}
+	if cfg.RedactInput, err = envBool("DIFFSCRIBE_REDACT_INPUT", true); err != nil {
+		return cfg, err
+	}
+	if cfg.ScrubPII, err = envBool("DIFFSCRIBE_SCRUB_PII", false); err != nil {
+		return cfg, err
+	}
+	if cfg.PIIPatterns, err = parsePIIPatterns(os.Getenv("DIFFSCRIBE_PII_PATTERNS")); err != nil {
+		return cfg, err
+	}
+	if cfg.SafeMode {
+		cfg.Outputs = slices.DeleteFunc(cfg.Outputs, func(t OutputTarget) bool { return t == OutputBody })
+		if !hasOutputTarget(cfg.Outputs, OutputComment) && !hasOutputTarget(cfg.Outputs, OutputSuggest) {
+			cfg.Outputs = append(cfg.Outputs, OutputComment)
+		}
+	}
+	if cfg.Cooldown, err = envDuration("DIFFSCRIBE_COOLDOWN", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.CacheTTL, err = envDuration("DIFFSCRIBE_CACHE_TTL", 7*24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.ExtraParams, err = envJSONObject("DIFFSCRIBE_EXTRA_PARAMS"); err != nil {
+		return cfg, err
+	}
+	if cfg.MergeSystem, err = envBool("DIFFSCRIBE_MERGE_SYSTEM", false); err != nil {
+		return cfg, err
+	}
+	if cfg.StructuredOutput, err = envBool("DIFFSCRIBE_STRUCTURED_OUTPUT", false); err != nil {
+		return cfg, err
+	}
+	if cfg.UsageFooter, err = envBool("DIFFSCRIBE_USAGE_FOOTER", false); err != nil {
+		return cfg, err
+	}
+	prices, err := envJSONObject("DIFFSCRIBE_MODEL_PRICES")
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.ModelPrices, err = parseModelPrices(prices); err != nil {
+		return cfg, err
+	}
+	if cfg.Debug, err = envBool("DIFFSCRIBE_DEBUG", false); err != nil {
+		return cfg, err
+	}
+	if cfg.RPM, err = envInt("DIFFSCRIBE_RPM", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.RPMBurst, err = envInt("DIFFSCRIBE_RPM_BURST", 1); err != nil {
+		return cfg, err
+	}
+	if cfg.RateLimitRetries, err = envInt("DIFFSCRIBE_RATE_LIMIT_RETRIES", 3); err != nil {
+		return cfg, err
+	}
+
+	if len(cfg.WIPPrefixes) == 1 && cfg.WIPPrefixes[0] == "none" {
+		cfg.WIPPrefixes = nil
+	}
+	if cfg.WIPAction != "skip" && cfg.WIPAction != "remind" {
+		return cfg, fmt.Errorf("DIFFSCRIBE_WIP_ACTION must be skip or remind, got %q", cfg.WIPAction)
+	}
+	if cfg.DisabledPath == "none" {
+		cfg.DisabledPath = ""
+	}
+
+	fallbackToken := os.Getenv("GITHUB_TOKEN")
+	cfg.GitHubToken = envString("DIFFSCRIBE_GITHUB_TOKEN", fallbackToken)
+	cfg.ModelsToken = envString("DIFFSCRIBE_MODELS_TOKEN", fallbackToken)
+
+	// PR_NUMBER is checked by main, since batch mode does not need it.
+	if cfg.GitHubToken == "" || cfg.ModelsToken == "" || cfg.Repository == "" {
+		return cfg, fmt.Errorf("required environment variables (GITHUB_TOKEN or DIFFSCRIBE_GITHUB_TOKEN/DIFFSCRIBE_MODELS_TOKEN, GITHUB_REPOSITORY) are not set")
+	}
+	return cfg, nil
+}
+
+// envString reads an environment variable, returning def when it is unset or empty.
+func envString(name, def string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
+
+// envInt reads an integer environment variable, returning def when it is unset or empty.
+func envInt(name string, def int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return def, fmt.Errorf("%s must be an integer, got %q", name, raw)
+	}
+	return v, nil
+}
+
+// envFloat reads a floating-point environment variable, returning def when it is unset or
+// empty.
+func envFloat(name string, def float64) (float64, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return def, fmt.Errorf("%s must be a number, got %q", name, raw)
+	}
+	return v, nil
+}
+
+// envBool reads a boolean environment variable ("true", "1", "false", ...), returning def
+// when it is unset or empty.
+func envBool(name string, def bool) (bool, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return def, fmt.Errorf("%s must be true or false, got %q", name, raw)
+	}
+	return v, nil
+}
+
+// envDuration reads a Go duration ("90s", "5m", ...), returning def when it is unset or empty.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		return def, fmt.Errorf("%s must be a duration such as 5m, got %q", name, raw)
+	}
+	return v, nil
+}
+
+// envJSONObject reads an environment variable holding a JSON object, returning nil when it is
+// unset or empty.
+func envJSONObject(name string) (map[string]any, error) {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return nil, nil
+	}
+	var v map[string]any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON object: %v", name, err)
+	}
+	return v, nil
+}
+
+// envList reads a comma-separated environment variable, returning def when it is unset or empty.
+func envList(name string, def []string) []string {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
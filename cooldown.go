@@ -0,0 +1,33 @@
+package main
+
+import (
+	"regexp"
+	"time"
+)
+
+// runMarkerPattern matches the hidden marker DiffScribe leaves in its notice comment,
+// recording when the run started.
+var runMarkerPattern = regexp.MustCompile(`<!-- diffscribe:run (\S+) -->`)
+
+// runMarker returns the hidden marker for a run started at t.
+func runMarker(t time.Time) string {
+	return "<!-- diffscribe:run " + t.UTC().Format(time.RFC3339) + " -->"
+}
+
+// lastRunWithin returns the start of the most recent DiffScribe run on the PR, as recorded
+// in the markers of its comments, when it lies within cooldown of now.
+func lastRunWithin(cfg Config, cooldown time.Duration, now time.Time) (time.Time, bool, error) {
+	comments, err := fetchIssueComments(cfg.Repository, cfg.PRNumber, now.Add(-cooldown), cfg.GitHubToken)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var last time.Time
+	for _, c := range comments {
+		for _, m := range runMarkerPattern.FindAllStringSubmatch(c.Body, -1) {
+			if t, err := time.Parse(time.RFC3339, m[1]); err == nil && t.After(last) {
+				last = t
+			}
+		}
+	}
+	return last, !last.IsZero() && now.Sub(last) < cooldown, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// dependencyChangesHeading is the heading of the deterministic dependency summary section.
+const dependencyChangesHeading = "## Dependency changes"
+
+// dependencyLineParsers extract a (name, version) pair from one manifest line, keyed by the
+// manifest's file name; requirements files are matched by prefix in manifestParser.
+var dependencyLineParsers = map[string]func(line string) (name, version string, ok bool){
+	"go.mod":         parseGoModLine,
+	"package.json":   parseJSONDependencyLine,
+	"Cargo.toml":     parseTOMLDependencyLine,
+	"pyproject.toml": parseTOMLDependencyLine,
+}
+
+var (
+	goModLinePattern       = regexp.MustCompile(`^(?:require\s+)?([A-Za-z0-9][^\s]*\.[^\s]+)\s+(v[0-9][^\s]*)`)
+	requirementLinePattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_.\-]*(?:\[[^\]]*\])?)\s*((?:[<>=!~]=?|===)\s*[^\s;#]+)?`)
+	jsonDependencyPattern  = regexp.MustCompile(`^"(@?[A-Za-z0-9][^"]*)"\s*:\s*"([~^<>=*]?[0-9*x][^"]*|latest|workspace:[^"]*|npm:[^"]*)"`)
+	tomlDependencyPattern  = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_\-]*)\s*=\s*(?:"([^"]+)"|\{.*version\s*=\s*"([^"]+)")`)
+)
+
+// manifestKeys are keys of package manifests that look like dependencies but are not.
+var manifestKeys = map[string]bool{
+	"name": true, "version": true, "description": true, "main": true, "license": true,
+	"edition": true, "rust-version": true, "requires-python": true, "node": true,
+}
+
+// extractDependencyChanges lists the dependencies added, removed or updated by the manifest
+// files (go.mod, package.json, requirements*.txt, Cargo.toml, pyproject.toml) in diff, one
+// human-readable line each, e.g. "go.mod: updated golang.org/x/net v0.1.0 → v0.2.0".
+func extractDependencyChanges(diff string) []string {
+	var changes []string
+	for _, f := range splitDiffFiles(diff) {
+		parse := manifestParser(f.Path)
+		if parse == nil {
+			continue
+		}
+		removed, added := map[string]string{}, map[string]string{}
+		for _, line := range strings.Split(f.Text, "\n") {
+			if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") || line == "" {
+				continue
+			}
+			target := added
+			switch line[0] {
+			case '+':
+			case '-':
+				target = removed
+			default:
+				continue
+			}
+			if name, version, ok := parse(strings.TrimSpace(line[1:])); ok {
+				target[name] = version
+			}
+		}
+		changes = append(changes, describeDependencyChanges(f.Path, removed, added)...)
+	}
+	return changes
+}
+
+// manifestParser returns the line parser for a manifest path, or nil for other files.
+func manifestParser(p string) func(string) (string, string, bool) {
+	base := path.Base(p)
+	if strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt") {
+		return parseRequirementLine
+	}
+	return dependencyLineParsers[base]
+}
+
+// describeDependencyChanges renders the sorted change lines for one manifest.
+func describeDependencyChanges(file string, removed, added map[string]string) []string {
+	var lines []string
+	for name, version := range added {
+		old, existed := removed[name]
+		switch {
+		case !existed:
+			lines = append(lines, fmt.Sprintf("%s: added %s", file, withVersion(name, version)))
+		case old != version:
+			lines = append(lines, fmt.Sprintf("%s: updated %s %s → %s", file, name, orAny(old), orAny(version)))
+		}
+	}
+	for name, version := range removed {
+		if _, kept := added[name]; !kept {
+			lines = append(lines, fmt.Sprintf("%s: removed %s", file, withVersion(name, version)))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// withVersion formats a dependency name with its version, when known.
+func withVersion(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + " " + version
+}
+
+// orAny stands in for an unpinned version.
+func orAny(version string) string {
+	if version == "" {
+		return "(any)"
+	}
+	return version
+}
+
+// parseGoModLine reads "module/path v1.2.3" lines, inside or outside a require block.
+func parseGoModLine(line string) (string, string, bool) {
+	m := goModLinePattern.FindStringSubmatch(line)
+	if m == nil || strings.HasPrefix(line, "module ") || strings.HasPrefix(line, "replace ") {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// parseRequirementLine reads pip requirement lines such as "requests==2.31.0".
+func parseRequirementLine(line string) (string, string, bool) {
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+		return "", "", false
+	}
+	m := requirementLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	return strings.ToLower(m[1]), strings.ReplaceAll(m[2], " ", ""), true
+}
+
+// parseJSONDependencyLine reads package.json entries such as `"react": "^18.2.0",`.
+func parseJSONDependencyLine(line string) (string, string, bool) {
+	m := jsonDependencyPattern.FindStringSubmatch(line)
+	if m == nil || manifestKeys[m[1]] {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// parseTOMLDependencyLine reads Cargo/Poetry entries such as `serde = "1.0"` or
+// `tokio = { version = "1", features = [...] }`.
+func parseTOMLDependencyLine(line string) (string, string, bool) {
+	m := tomlDependencyPattern.FindStringSubmatch(line)
+	if m == nil || manifestKeys[m[1]] {
+		return "", "", false
+	}
+	version := m[2]
+	if version == "" {
+		version = m[3]
+	}
+	return m[1], version, true
+}
+
+// appendDependencyChanges appends the dependency change list under "## Dependency changes"
+// unless the body already has such a section.
+func appendDependencyChanges(body string, changes []string) string {
+	if len(changes) == 0 {
+		return body
+	}
+	if _, exists := sectionsByKey(body, nil)[sectionKey(strings.TrimLeft(dependencyChangesHeading, "# "))]; exists {
+		return body
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(body, "\n"))
+	b.WriteString("\n\n" + dependencyChangesHeading + "\n")
+	for _, c := range changes {
+		b.WriteString("- " + c + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDeterministicFiles caps the file list of a deterministic description.
+const maxDeterministicFiles = 50
+
+// buildDeterministicDescription fills the template's summary section (or, without one, its
+// first section still holding only placeholders) with the changed files and line counts from
+// diff, leaving every other placeholder in place. No model is involved.
+func buildDeterministicDescription(template, diff string) string {
+	summary := deterministicSummary(diff)
+	sections := splitSections(template)
+
+	target := -1
+	for _, heading := range summaryHeadings {
+		for i, s := range sections {
+			if s.Heading != "" && sectionKey(s.Title()) == heading {
+				target = i
+				break
+			}
+		}
+		if target >= 0 {
+			break
+		}
+	}
+	if target < 0 {
+		for i, s := range sections {
+			if s.Heading != "" && isPlaceholderOnly(s.Body) {
+				target = i
+				break
+			}
+		}
+	}
+	if target < 0 {
+		return "## Summary\n" + summary + "\n" + template
+	}
+
+	sections[target].Body = summary + "\n"
+	return joinSections(sections)
+}
+
+// deterministicSummary lists the files changed by diff with their added/removed line counts.
+func deterministicSummary(diff string) string {
+	var files []fileDiff
+	additions, deletions := 0, 0
+	for _, f := range splitDiffFiles(diff) {
+		if f.Path == "" {
+			continue
+		}
+		files = append(files, f)
+		additions += f.Additions
+		deletions += f.Deletions
+	}
+	if len(files) == 0 {
+		return "_No file changes were found in the diff._\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "This PR changes %d file(s) (+%d/-%d lines):\n\n", len(files), additions, deletions)
+	for i, f := range files {
+		if i == maxDeterministicFiles {
+			fmt.Fprintf(&b, "- … and %d more file(s)\n", len(files)-maxDeterministicFiles)
+			break
+		}
+		name := "`" + f.Path + "`"
+		if f.OldPath != "" && f.OldPath != f.Path {
+			name = "`" + f.OldPath + "` → " + name
+		}
+		fmt.Fprintf(&b, "- %s (+%d/-%d)\n", name, f.Additions, f.Deletions)
+	}
+	b.WriteString("\n_This summary was generated from the diff statistics only; please describe the change in your own words._\n")
+	return b.String()
+}
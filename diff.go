@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// truncatedMarker is appended to truncated diffs and to descriptions generated from them,
+// so downstream tooling can tell the model only saw part of the change.
+const truncatedMarker = "<!-- diffscribe:truncated -->"
+
+// defaultTruncationNotice is the human-readable suffix added to truncated diffs.
+const defaultTruncationNotice = "... (diff truncated to fit context window)"
+
+// truncateDiff cuts diff to at most maxSize bytes on file and hunk boundaries (see cutDiff),
+// appending notice and truncatedMarker, and reports whether truncation happened.
+func truncateDiff(diff string, maxSize int, notice string) (string, bool) {
+	if len(diff) <= maxSize {
+		return diff, false
+	}
+	return cutDiff(diff, maxSize) + "\n\n" + notice + "\n" + truncatedMarker, true
+}
+
+// cutDiff returns a prefix of diff of at most size bytes made of whole files, then the
+// whole leading hunks of the first file that does not fit. When not even that file's first
+// hunk fits, the hunk keeps its leading lines and its header is rewritten to their line
+// counts, so the result is always a valid diff. Text that is not a diff is cut at a line
+// boundary.
+func cutDiff(diff string, size int) string {
+	var b strings.Builder
+	for _, f := range splitDiffFiles(diff) {
+		room := size - b.Len()
+		if len(f.Text) <= room {
+			b.WriteString(f.Text)
+			continue
+		}
+		header, hunks := splitHunks(f.Text)
+		if len(hunks) == 0 || len(header) >= room {
+			if b.Len() == 0 {
+				b.WriteString(cutLines(f.Text, room))
+			}
+			break
+		}
+		room -= len(header)
+		var kept strings.Builder
+		for _, h := range hunks {
+			if len(h) > room-kept.Len() {
+				if kept.Len() == 0 {
+					kept.WriteString(cutHunk(h, room))
+				}
+				break
+			}
+			kept.WriteString(h)
+		}
+		if kept.Len() > 0 {
+			b.WriteString(header + kept.String())
+		}
+		break
+	}
+	return b.String()
+}
+
+// hunkHeaderPattern matches a hunk header, capturing the old and new start lines and the
+// text after the closing "@@".
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@(.*)$`)
+
+// cutHunk keeps the leading lines of hunk that fit in size bytes and rewrites its header to
+// their old and new line counts; it returns "" when no line of the body fits.
+func cutHunk(hunk string, size int) string {
+	lines := strings.SplitAfter(hunk, "\n")
+	m := hunkHeaderPattern.FindStringSubmatch(strings.TrimRight(lines[0], "\n"))
+	if m == nil {
+		return cutLines(hunk, size)
+	}
+	// The rewritten counts may add ",N" to a header that omitted them.
+	room := size - len(lines[0]) - 8
+	var body strings.Builder
+	oldLines, newLines := 0, 0
+	for _, line := range lines[1:] {
+		if line == "" || body.Len()+len(line) > room {
+			break
+		}
+		switch line[0] {
+		case '-':
+			oldLines++
+		case '+':
+			newLines++
+		case '\\':
+		default:
+			oldLines++
+			newLines++
+		}
+		body.WriteString(line)
+	}
+	if body.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("@@ -%s,%d +%s,%d @@%s\n", m[1], oldLines, m[2], newLines, m[3]) + body.String()
+}
+
+// cutLines returns the longest prefix of text of at most size bytes that ends at a line
+// break, or the plain byte prefix when its first line alone is longer.
+func cutLines(text string, size int) string {
+	if len(text) <= size {
+		return text
+	}
+	if nl := strings.LastIndexByte(text[:size], '\n'); nl >= 0 {
+		return text[:nl+1]
+	}
+	return text[:size]
+}
+
+// markTruncated appends truncatedMarker to a generated description unless it is already there.
+func markTruncated(body string) string {
+	if strings.Contains(body, truncatedMarker) {
+		return body
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + truncatedMarker + "\n"
+}
+
+// fileDiff is the part of a unified diff that describes a single file.
+type fileDiff struct {
+	Path      string // post-change path ("b/" side)
+	OldPath   string // pre-change path ("a/" side)
+	Text      string // the complete block, starting at its "diff --git" line
+	Additions int
+	Deletions int
+}
+
+// splitDiffFiles splits a unified diff into per-file blocks. Any text before the first
+// "diff --git" header is returned as a block with an empty Path, so joinDiffFiles always
+// reproduces the input.
+func splitDiffFiles(diff string) []fileDiff {
+	var files []fileDiff
+	var current *fileDiff
+	inHunk := false
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			files = append(files, parseDiffHeader(strings.TrimRight(line, "\n")))
+			current = &files[len(files)-1]
+			inHunk = false
+		} else if current == nil {
+			files = append(files, fileDiff{})
+			current = &files[len(files)-1]
+		}
+		current.Text += line
+
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && strings.HasPrefix(line, "+"):
+			current.Additions++
+		case inHunk && strings.HasPrefix(line, "-"):
+			current.Deletions++
+		}
+	}
+	return files
+}
+
+// parseDiffHeader reads the old and new paths from a "diff --git a/<old> b/<new>" line.
+func parseDiffHeader(line string) fileDiff {
+	fd := fileDiff{}
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if idx := strings.LastIndex(rest, " b/"); idx >= 0 {
+		fd.OldPath = strings.TrimPrefix(rest[:idx], "a/")
+		fd.Path = rest[idx+3:]
+	}
+	return fd
+}
+
+// splitHunks splits a file block into its header (everything before the first "@@" line)
+// and its hunks, each starting at its "@@" line; header plus the joined hunks reproduce text.
+func splitHunks(text string) (header string, hunks []string) {
+	var current strings.Builder
+	inHunk := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			if inHunk {
+				hunks = append(hunks, current.String())
+			} else {
+				header = current.String()
+			}
+			current.Reset()
+			inHunk = true
+		}
+		current.WriteString(line)
+	}
+	if inHunk {
+		hunks = append(hunks, current.String())
+	} else {
+		header = current.String()
+	}
+	return header, hunks
+}
+
+// filesToDiff assembles a unified diff from the PR files API, with git-style headers so the
+// rest of the pipeline treats it like the diff endpoint's output. Files without a patch,
+// other than pure renames, get a one-line note with their line counts.
+func filesToDiff(files []PullFile) string {
+	var b strings.Builder
+	for _, f := range files {
+		oldPath := f.Filename
+		if f.PreviousFilename != "" {
+			oldPath = f.PreviousFilename
+		}
+		fmt.Fprintf(&b, "diff --git a/%s b/%s\n", oldPath, f.Filename)
+		switch f.Status {
+		case "added":
+			b.WriteString("new file mode 100644\n")
+		case "removed":
+			b.WriteString("deleted file mode 100644\n")
+		case "renamed":
+			fmt.Fprintf(&b, "rename from %s\nrename to %s\n", oldPath, f.Filename)
+		}
+		if f.Patch == "" {
+			if f.Status == "renamed" && f.Additions+f.Deletions == 0 {
+				continue // a pure rename has no content change
+			}
+			fmt.Fprintf(&b, "(no patch available: binary or too large file, +%d/-%d lines)\n", f.Additions, f.Deletions)
+			continue
+		}
+		from, to := "a/"+oldPath, "b/"+f.Filename
+		if f.Status == "added" {
+			from = "/dev/null"
+		} else if f.Status == "removed" {
+			to = "/dev/null"
+		}
+		fmt.Fprintf(&b, "--- %s\n+++ %s\n%s\n", from, to, strings.TrimRight(f.Patch, "\n"))
+	}
+	return b.String()
+}
+
+// joinDiffFiles reassembles blocks produced by splitDiffFiles.
+func joinDiffFiles(files []fileDiff) string {
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f.Text)
+	}
+	return b.String()
+}
+
+// changedFiles lists the paths touched by a unified diff, using the post-change path for
+// renames (and the old path for deletions, which git records under the same header).
+func changedFiles(diff string) []string {
+	var paths []string
+	for _, f := range splitDiffFiles(diff) {
+		if f.Path != "" {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// capLargeFiles replaces the diff of every file whose block exceeds maxBytes with a one-line
+// note, so a single huge generated or data file cannot crowd out the rest of the change.
+func capLargeFiles(diff string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return diff
+	}
+	files := splitDiffFiles(diff)
+	for i, f := range files {
+		if f.Path == "" || len(f.Text) <= maxBytes {
+			continue
+		}
+		files[i].Text = fileHeaderLine(f) + fmt.Sprintf("(large file changed: %s, +%d/-%d lines, omitted)\n", f.Path, f.Additions, f.Deletions)
+	}
+	return joinDiffFiles(files)
+}
+
+// redactedContentNote replaces the diff content of files matched by DIFFSCRIBE_REDACT_PATHS.
+const redactedContentNote = "(content redacted by policy)"
+
+// redactPaths replaces the content of every file whose path (old or new) matches one of the
+// gitignore-style globs with redactedContentNote, keeping its header and line counts so the
+// model still knows the file changed.
+func redactPaths(diff string, globs []string) string {
+	if len(globs) == 0 {
+		return diff
+	}
+	files := splitDiffFiles(diff)
+	for i, f := range files {
+		if f.Path == "" {
+			continue
+		}
+		for _, glob := range globs {
+			if matchPathPattern(glob, f.Path) || (f.OldPath != "" && matchPathPattern(glob, f.OldPath)) {
+				files[i].Text = fileHeaderLine(f) + fmt.Sprintf("%s: %s, +%d/-%d lines\n", redactedContentNote, f.Path, f.Additions, f.Deletions)
+				break
+			}
+		}
+	}
+	return joinDiffFiles(files)
+}
+
+// fileHeaderLine returns the "diff --git" line that opens a file block.
+func fileHeaderLine(f fileDiff) string {
+	if nl := strings.IndexByte(f.Text, '\n'); nl >= 0 {
+		return f.Text[:nl+1]
+	}
+	return f.Text + "\n"
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testDiff builds a diff of n files, each with one hunk of lines added lines.
+func testDiff(n, lines int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "diff --git a/file%d.go b/file%d.go\n--- a/file%d.go\n+++ b/file%d.go\n@@ -0,0 +1,%d @@\n", i, i, i, i, lines)
+		for j := 0; j < lines; j++ {
+			fmt.Fprintf(&b, "+line %d of file %d\n", j, i)
+		}
+	}
+	return b.String()
+}
+
+func TestTruncationMarker(t *testing.T) {
+	const notice = "... (diff gekürzt)"
+	diff := testDiff(10, 20)
+	for _, strategy := range []string{strategyHead, strategyHeadTail, strategyPrioritize} {
+		t.Run(strategy, func(t *testing.T) {
+			cfg := Config{TruncateStrategy: strategy, TruncationNotice: notice}
+
+			got, truncated := reduceDiff(diff, len(diff)/3, cfg)
+			if !truncated || !strings.Contains(got, truncatedMarker) || !strings.Contains(got, notice) {
+				t.Errorf("truncated diff: truncated = %v, want the marker and the configured notice in:\n%s", truncated, got)
+			}
+
+			got, truncated = reduceDiff(diff, len(diff), cfg)
+			if truncated || got != diff || strings.Contains(got, truncatedMarker) {
+				t.Errorf("diff within the limit: truncated = %v, want it unchanged and unmarked", truncated)
+			}
+		})
+	}
+}
+
+func TestMarkTruncated(t *testing.T) {
+	once := markTruncated("## Summary\nAdds caching.\n")
+	if !strings.HasSuffix(once, truncatedMarker+"\n") {
+		t.Fatalf("markTruncated = %q, want the marker appended", once)
+	}
+	if twice := markTruncated(once); twice != once {
+		t.Errorf("markTruncated is not idempotent: %q", twice)
+	}
+}
+
+func TestCutDiffKeepsWholeHunks(t *testing.T) {
+	diff := testDiff(3, 10)
+	got := cutDiff(diff, len(diff)/2)
+	if len(got) > len(diff)/2 {
+		t.Fatalf("cutDiff returned %d bytes, over the %d limit", len(got), len(diff)/2)
+	}
+	for _, f := range splitDiffFiles(got) {
+		_, hunks := splitHunks(f.Text)
+		for _, h := range hunks {
+			var want, added int
+			header, body, _ := strings.Cut(h, "\n")
+			if _, err := fmt.Sscanf(header, "@@ -0,0 +1,%d @@", &want); err != nil {
+				t.Fatalf("bad hunk header %q: %v", header, err)
+			}
+			added = strings.Count(body, "\n+")
+			if strings.HasPrefix(body, "+") {
+				added++
+			}
+			if added != want {
+				t.Errorf("%s: hunk header says %d lines, body has %d", f.Path, want, added)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultOnEvents are the pull_request actions DiffScribe reacts to by default.
+var defaultOnEvents = []string{"opened", "ready_for_review"}
+
+// Event is the subset of the GitHub webhook payload DiffScribe inspects.
+type Event struct {
+	Action string `json:"action"`
+
+	// Issue and Comment are set for issue_comment events.
+	Issue *struct {
+		Number      int       `json:"number"`
+		PullRequest *struct{} `json:"pull_request"`
+	} `json:"issue"`
+	Comment *struct {
+		Body string `json:"body"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		// AuthorAssociation is the commenter's relationship to the repository, e.g. "OWNER",
+		// "MEMBER", "COLLABORATOR" or "CONTRIBUTOR".
+		AuthorAssociation string `json:"author_association"`
+	} `json:"comment"`
+}
+
+// loadEvent reads the webhook payload at path (GITHUB_EVENT_PATH). An empty path yields a
+// zero Event, as for local or manual runs.
+func loadEvent(path string) (Event, error) {
+	var ev Event
+	if path == "" {
+		return ev, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ev, fmt.Errorf("failed to read event payload: %w", err)
+	}
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return ev, fmt.Errorf("failed to parse event payload: %w", err)
+	}
+	return ev, nil
+}
+
+// eventAllowed reports whether a webhook action should trigger processing. Runs without an
+// action (manual or local invocations) are always processed.
+func eventAllowed(action string, allowed []string) bool {
+	if action == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// authorExcluded reports why a PR by login should be skipped, or "" when it qualifies. An
+// entry of "[bot]" matches every bot account; other entries match logins case-insensitively.
+func authorExcluded(login string, skip, only []string) string {
+	if authorListed(login, skip) {
+		return fmt.Sprintf("author %s is in DIFFSCRIBE_SKIP_AUTHORS", login)
+	}
+	if len(only) > 0 && !authorListed(login, only) {
+		return fmt.Sprintf("author %s is not in DIFFSCRIBE_ONLY_AUTHORS", login)
+	}
+	return ""
+}
+
+// authorListed reports whether login matches any entry of list.
+func authorListed(login string, list []string) bool {
+	for _, entry := range list {
+		if entry == "[bot]" && strings.HasSuffix(strings.ToLower(login), "[bot]") {
+			return true
+		}
+		if strings.EqualFold(entry, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultWIPPrefixes are the title prefixes that mark a PR as work in progress.
+var defaultWIPPrefixes = []string{"WIP", "[WIP]", "Draft:", "[Draft]"}
+
+// wipPrefix returns the entry of prefixes that title starts with, ignoring case. A prefix
+// made only of letters (e.g. "WIP") must be followed by a non-letter, so "Wipe cache" is not
+// treated as work in progress.
+func wipPrefix(title string, prefixes []string) (string, bool) {
+	title = strings.TrimSpace(title)
+	for _, p := range prefixes {
+		if len(title) < len(p) || !strings.EqualFold(title[:len(p)], p) {
+			continue
+		}
+		if rest := title[len(p):]; rest != "" && isLetter(p[len(p)-1]) && isLetter(rest[0]) {
+			continue
+		}
+		return p, true
+	}
+	return "", false
+}
+
+// isLetter reports whether b is an ASCII letter.
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// prTooLarge reports why a PR is too large to describe reliably, or "" when it is within
+// maxFiles changed files and maxLines changed lines (0 disables a limit).
+func prTooLarge(pr *PullRequest, maxFiles, maxLines int) string {
+	if maxFiles > 0 && pr.ChangedFiles > maxFiles {
+		return fmt.Sprintf("the PR changes %d files, more than DIFFSCRIBE_MAX_FILES (%d)", pr.ChangedFiles, maxFiles)
+	}
+	if lines := pr.Additions + pr.Deletions; maxLines > 0 && lines > maxLines {
+		return fmt.Sprintf("the PR changes %d lines, more than DIFFSCRIBE_MAX_CHANGED_LINES (%d)", lines, maxLines)
+	}
+	return ""
+}
+
+// authorOrTitleSkipped reports whether the PR is skipped for its author
+// (DIFFSCRIBE_SKIP_AUTHORS, DIFFSCRIBE_ONLY_AUTHORS) or its work-in-progress title
+// (DIFFSCRIBE_WIP_PREFIXES), posting a reminder on WIP PRs with DIFFSCRIBE_WIP_ACTION=remind.
+func authorOrTitleSkipped(rc *runContext) (bool, error) {
+	cfg := rc.cfg
+	if len(cfg.SkipAuthors) == 0 && len(cfg.OnlyAuthors) == 0 && len(cfg.WIPPrefixes) == 0 {
+		return false, nil
+	}
+	pr, err := rc.pullRequest()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch PR details: %w", err)
+	}
+	if reason := authorExcluded(pr.User.Login, cfg.SkipAuthors, cfg.OnlyAuthors); reason != "" {
+		log.Printf("Skipping DiffScribe: %s.", reason)
+		return true, nil
+	}
+	if prefix, ok := wipPrefix(pr.Title, cfg.WIPPrefixes); ok {
+		log.Printf("Skipping DiffScribe: title %q starts with work-in-progress prefix %q.", pr.Title, prefix)
+		if cfg.WIPAction == "remind" {
+			if err := postWIPReminder(cfg.Repository, cfg.PRNumber, cfg.GitHubToken); err != nil {
+				log.Printf("Warning: failed to post work-in-progress reminder: %v", err)
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// prSkipped reports whether the PR is skipped before its diff is fetched: its description is
+// already filled, DiffScribe ran on it within DIFFSCRIBE_COOLDOWN, or it exceeds
+// DIFFSCRIBE_MAX_FILES or DIFFSCRIBE_MAX_CHANGED_LINES (which is explained in a comment).
+func prSkipped(rc *runContext, template string) bool {
+	cfg := rc.cfg
+	if !isTemplateUnfilled(cfg.PRBody, template) {
+		log.Println("PR description appears to be already filled. Skipping DiffScribe.")
+		if hasOutputTarget(cfg.Outputs, OutputCheckRun) {
+			if err := reportCheckRun(rc, "neutral", "Description already filled",
+				"The PR description was already filled in, so DiffScribe left it unchanged."); err != nil {
+				log.Printf("Warning: failed to create check run: %v", err)
+			}
+		}
+		return true
+	}
+
+	if cfg.Cooldown > 0 {
+		last, recent, err := lastRunWithin(cfg, cfg.Cooldown, time.Now())
+		if err != nil {
+			log.Printf("Warning: failed to check the cooldown: %v", err)
+		} else if recent {
+			log.Printf("Skipping DiffScribe: it last ran on this PR at %s, within the %s cooldown (DIFFSCRIBE_COOLDOWN).",
+				last.Format(time.RFC3339), cfg.Cooldown)
+			return true
+		}
+	}
+
+	if cfg.MaxFiles > 0 || cfg.MaxChangedLines > 0 {
+		if pr, err := rc.pullRequest(); err != nil {
+			log.Printf("Warning: failed to fetch the PR size for DIFFSCRIBE_MAX_FILES/DIFFSCRIBE_MAX_CHANGED_LINES: %v", err)
+		} else if reason := prTooLarge(pr, cfg.MaxFiles, cfg.MaxChangedLines); reason != "" {
+			log.Printf("Skipping DiffScribe: %s.", reason)
+			if err := postTooLargeComment(cfg.Repository, cfg.PRNumber, cfg.GitHubToken, reason); err != nil {
+				log.Printf("Warning: failed to post the too-large comment: %v", err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// handleEvent filters the triggering webhook event and runs DiffScribe when it qualifies.
+func handleEvent(cfg Config) error {
+	ev, err := loadEvent(cfg.EventPath)
+	if err != nil {
+		return err
+	}
+	if cfg.EventName == "issue_comment" {
+		return handleExplainCommand(cfg, ev)
+	}
+	if !eventAllowed(ev.Action, cfg.OnEvents) {
+		log.Printf("Ignoring %q event (DIFFSCRIBE_ON_EVENTS=%v). Skipping DiffScribe.", ev.Action, cfg.OnEvents)
+		return nil
+	}
+	_, err = run(cfg)
+	return err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// explainCommand is the PR comment that requests an on-demand explanation.
+const explainCommand = "/diffscribe explain"
+
+// commandAssociations are the comment author associations allowed to run commands, as each
+// one costs model calls.
+var commandAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+// isExplainCommand reports whether a comment body invokes the explain command.
+func isExplainCommand(body string) bool {
+	fields := strings.Fields(strings.ToLower(body))
+	return len(fields) >= 2 && fields[0]+" "+fields[1] == explainCommand
+}
+
+// handleExplainCommand answers a "/diffscribe explain" PR comment with a plain-English
+// explanation of the PR, posted as a reply. The PR body is never touched.
+func handleExplainCommand(cfg Config, ev Event) error {
+	if ev.Issue == nil || ev.Issue.PullRequest == nil || ev.Comment == nil {
+		log.Println("Comment is not on a pull request. Skipping DiffScribe.")
+		return nil
+	}
+	if ev.Action != "created" || !isExplainCommand(ev.Comment.Body) {
+		log.Println("Comment does not contain a DiffScribe command. Skipping.")
+		return nil
+	}
+	if !slices.Contains(commandAssociations, ev.Comment.AuthorAssociation) {
+		log.Printf("Ignoring %q from @%s (%s): only owners, members and collaborators may run it.",
+			explainCommand, ev.Comment.User.Login, ev.Comment.AuthorAssociation)
+		return nil
+	}
+	log.Printf("Received %q from @%s", explainCommand, ev.Comment.User.Login)
+
+	rc := &runContext{cfg: cfg}
+	diff, err := fetchDiff(rc)
+	if err != nil {
+		return fmt.Errorf("failed to fetch PR diff: %w", err)
+	}
+	diff = filterPromptDiff(redactPaths(diff, cfg.RedactPaths), cfg)
+	diff, _ = promptDiff(cfg, diff, approxTokens(descriptionSystemPrompt+explainPrompt("")))
+
+	explanation, err := explainDiff(diff)
+	if err != nil {
+		return fmt.Errorf("failed to generate explanation: %w", err)
+	}
+	explanation, _ = redactSecrets(explanation)
+
+	reply := fmt.Sprintf("> %s\n\n@%s here is an overview of this PR:\n\n%s\n\n%s",
+		explainCommand, ev.Comment.User.Login, strings.TrimSpace(explanation), commentFooter)
+	if err := postIssueComment(cfg.Repository, cfg.PRNumber, cfg.GitHubToken, reply); err != nil {
+		return fmt.Errorf("failed to post explanation: %w", err)
+	}
+	log.Println("Explanation posted on PR.")
+	return nil
+}
+
+// explainDiff asks the model for a reviewer-oriented, plain-English summary of a diff.
+func explainDiff(diff string) (string, error) {
+	return chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: descriptionSystemPrompt},
+			{Role: "user", Content: explainPrompt(diff)},
+		},
+		MaxTokens:   800,
+		Temperature: 0.3,
+	})
+}
+
+// explainPrompt is the user prompt of explainDiff.
+func explainPrompt(diff string) string {
+	return fmt.Sprintf(`Explain the following Pull Request to a reviewer in plain English.
+
+## Code Diff
+%s
+
+## Instructions
+1. Start with one or two sentences on what the PR does overall.
+2. Follow with a short bullet list of the most important changes and anything a reviewer should look at closely.
+3. Do not speculate beyond what the diff shows. Keep it under 250 words.`, diff)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIsExplainCommand(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{body: "/diffscribe explain", want: true},
+		{body: "  /DiffScribe Explain please", want: true},
+		{body: "/diffscribe\nexplain", want: true},
+		{body: "/diffscribe", want: false},
+		{body: "please /diffscribe explain", want: false},
+		{body: "/diffscribe explained", want: false},
+	}
+	for _, tt := range tests {
+		if got := isExplainCommand(tt.body); got != tt.want {
+			t.Errorf("isExplainCommand(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestHandleExplainCommandIgnoresOutsiders(t *testing.T) {
+	for _, association := range []string{"CONTRIBUTOR", "FIRST_TIME_CONTRIBUTOR", "NONE", ""} {
+		t.Run(association, func(t *testing.T) {
+			var ev Event
+			payload := `{"action":"created","issue":{"number":1,"pull_request":{}},"comment":{"body":"/diffscribe explain","user":{"login":"someone"},"author_association":"` + association + `"}}`
+			if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+				t.Fatal(err)
+			}
+			// An empty Config has no GitHub API to reach, so anything past the gate would fail.
+			if err := handleExplainCommand(Config{}, ev); err != nil {
+				t.Errorf("handleExplainCommand = %v, want the command ignored", err)
+			}
+		})
+	}
+}
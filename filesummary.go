@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// fileSummariesHeading is the heading of the appended per-file summary list.
+const fileSummariesHeading = "## Changes by file"
+
+// summarizeFiles asks the model for a one-line summary of each file changed in diff, keyed by
+// path. Files the model does not mention are left out of the result.
+func summarizeFiles(diff string, paths []string) (map[string]string, error) {
+	list, _ := json.Marshal(paths)
+	prompt := fmt.Sprintf(`Summarise what this Pull Request diff changes in each file, in one short line per file (at most 15 words, no trailing period). Return ONLY a JSON object mapping each file path to its summary, for these paths: %s
+
+%s`, list, diff)
+
+	content, err := chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: "You are an expert software engineer who summarises code changes precisely and concisely."},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens:   min(40*len(paths)+100, 4000),
+		Temperature: 0.2,
+		JSON:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var summaries map[string]string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &summaries); err != nil {
+		return nil, fmt.Errorf("file summaries are not a JSON object of strings: %w", err)
+	}
+	return summaries, nil
+}
+
+// renderFileSummaries renders one bullet per changed file: the model's summary where there is
+// one, otherwise the kind of change and its line counts.
+func renderFileSummaries(changes FileChanges, summaries map[string]string) string {
+	var b strings.Builder
+	for _, c := range changes {
+		summary := strings.TrimSpace(strings.ReplaceAll(summaries[c.Path], "\n", " "))
+		if summary == "" {
+			summary = fmt.Sprintf("%s (+%d/-%d)", c.Type, c.Additions, c.Deletions)
+		}
+		fmt.Fprintf(&b, "- `%s` — %s\n", c.Path, summary)
+	}
+	return b.String()
+}
+
+// appendFileSummaries appends list under "## Changes by file" unless the body already has
+// such a section, so reruns never duplicate it.
+func appendFileSummaries(body, list string) string {
+	if list == "" {
+		return body
+	}
+	for _, s := range splitSections(body) {
+		if sectionKey(s.Title()) == sectionKey(strings.TrimLeft(fileSummariesHeading, "# ")) {
+			return body
+		}
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + fileSummariesHeading + "\n" + list
+}
+
+// changedFileSummaries summarises each changed file with DIFFSCRIBE_FILE_SUMMARIES. On
+// failure it returns nil, so the list falls back to line counts.
+func changedFileSummaries(rc *runContext, diff string) map[string]string {
+	if !rc.cfg.FileSummaries || len(rc.changedPaths) == 0 {
+		return nil
+	}
+	log.Printf("Summarising %d changed file(s)...", len(rc.changedPaths))
+	defer timings.Start("files")()
+	summaries, err := summarizeFiles(diff, rc.changedPaths)
+	if err != nil {
+		log.Printf("Warning: failed to summarise the changed files, listing their line counts instead: %v", err)
+	}
+	return summaries
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fileTableHeading is the heading of the appended file table when no section is designated.
+const fileTableHeading = "## Changed files"
+
+// FileChange is one file in the diff with its line counts and kind of change.
+type FileChange struct {
+	Path      string
+	OldPath   string
+	Additions int
+	Deletions int
+	Type      string // added, modified, deleted or renamed
+}
+
+// FileChanges lists the files of a diff in diff order.
+type FileChanges []FileChange
+
+// parseFileChanges classifies every file of a unified diff.
+func parseFileChanges(diff string) FileChanges {
+	var changes FileChanges
+	for _, f := range splitDiffFiles(diff) {
+		if f.Path == "" {
+			continue
+		}
+		change := FileChange{Path: f.Path, OldPath: f.OldPath, Additions: f.Additions, Deletions: f.Deletions, Type: "modified"}
+		header := f.Text
+		if i := strings.Index(header, "\n@@"); i >= 0 {
+			header = header[:i]
+		}
+		switch {
+		case strings.Contains(header, "\nnew file mode"):
+			change.Type = "added"
+		case strings.Contains(header, "\ndeleted file mode"):
+			change.Type = "deleted"
+		case strings.Contains(header, "\nrename from ") || (f.OldPath != "" && f.OldPath != f.Path):
+			change.Type = "renamed"
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// renderFileTable renders changes as a markdown table with a totals row.
+func renderFileTable(changes FileChanges) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("| File | Change | + | - |\n|---|---|---:|---:|\n")
+	additions, deletions := 0, 0
+	for _, c := range changes {
+		name := "`" + escapeTableCell(c.Path) + "`"
+		if c.Type == "renamed" && c.OldPath != "" {
+			name = "`" + escapeTableCell(c.OldPath) + "` → " + name
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", name, c.Type, c.Additions, c.Deletions)
+		additions += c.Additions
+		deletions += c.Deletions
+	}
+	fmt.Fprintf(&b, "| **%d file(s)** | | **%d** | **%d** |\n", len(changes), additions, deletions)
+	return b.String()
+}
+
+// escapeTableCell keeps a path from breaking the table layout.
+func escapeTableCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// insertFileTable adds table to the section named heading, or appends it under
+// "## Changed files" when heading is empty or not in body. A body already holding the
+// table's header row is left unchanged, so reruns never duplicate it.
+func insertFileTable(body, table, heading string, synonyms map[string]string) string {
+	if table == "" || strings.Contains(body, "| File | Change | + | - |") {
+		return body
+	}
+	if heading != "" {
+		key := canonicalizeHeading(heading, synonyms)
+		sections := splitSections(body)
+		for i, s := range sections {
+			if s.Heading != "" && canonicalizeHeading(s.Title(), synonyms) == key {
+				content := strings.TrimRight(htmlCommentPattern.ReplaceAllString(s.Body, ""), " \n")
+				if strings.TrimSpace(content) != "" {
+					content += "\n\n"
+				}
+				sections[i].Body = content + table + "\n"
+				return joinSections(sections)
+			}
+		}
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + fileTableHeading + "\n" + table
+}
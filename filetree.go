@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// defaultFileTreeBudget caps the bytes of the changed-file tree added to the prompt.
+const defaultFileTreeBudget = 2000
+
+// treeNode is a directory of the changed-file tree; files are leaves holding their change.
+type treeNode struct {
+	dirs  map[string]*treeNode
+	files []FileChange
+}
+
+// renderFileTree renders changes as an indented directory tree with per-file line counts and
+// a totals line, e.g.
+//
+//	internal/cache/
+//	  evict.go (+12/-3)
+//	README.md (+2/-0, added)
+//
+// Directories with a single subdirectory and no files are folded into one line. Lines beyond
+// budget bytes are only counted.
+func renderFileTree(changes FileChanges, budget int) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	root := &treeNode{dirs: map[string]*treeNode{}}
+	additions, deletions := 0, 0
+	for _, c := range changes {
+		node := root
+		parts := strings.Split(c.Path, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			if node.dirs[dir] == nil {
+				node.dirs[dir] = &treeNode{dirs: map[string]*treeNode{}}
+			}
+			node = node.dirs[dir]
+		}
+		node.files = append(node.files, c)
+		additions += c.Additions
+		deletions += c.Deletions
+	}
+
+	var lines []string
+	root.render("", &lines)
+	var b strings.Builder
+	for i, line := range lines {
+		if b.Len()+len(line) > budget {
+			fmt.Fprintf(&b, "... (%d more line(s))\n", len(lines)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	fmt.Fprintf(&b, "%d file(s) changed, +%d/-%d lines\n", len(changes), additions, deletions)
+	return b.String()
+}
+
+// render appends the node's directories, then its files, each level sorted by name.
+func (n *treeNode) render(indent string, lines *[]string) {
+	names := make([]string, 0, len(n.dirs))
+	for name := range n.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dir, label := n.dirs[name], name+"/"
+		for len(dir.files) == 0 && len(dir.dirs) == 1 {
+			for sub, child := range dir.dirs {
+				label += sub + "/"
+				dir = child
+			}
+		}
+		*lines = append(*lines, indent+label+"\n")
+		dir.render(indent+"  ", lines)
+	}
+
+	sort.Slice(n.files, func(i, j int) bool { return n.files[i].Path < n.files[j].Path })
+	for _, f := range n.files {
+		stats := fmt.Sprintf("+%d/-%d", f.Additions, f.Deletions)
+		if f.Type != "modified" {
+			stats += ", " + f.Type
+		}
+		if f.Type == "renamed" && f.OldPath != "" {
+			stats += " from " + f.OldPath
+		}
+		name := f.Path[strings.LastIndexByte(f.Path, '/')+1:]
+		*lines = append(*lines, fmt.Sprintf("%s%s (%s)\n", indent, name, stats))
+	}
+}
+
+// fileTreePart renders the changed files as a tree for the prompt with DIFFSCRIBE_FILE_TREE.
+func fileTreePart(cfg Config, changes FileChanges) promptPart {
+	if !cfg.FileTree {
+		return promptPart{}
+	}
+	return promptPart{ContextBlock: ContextBlock{Title: "Changed Files", Text: renderFileTree(changes, defaultFileTreeBudget)}}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+)
+
+// geminiBase is the Gemini API used by DIFFSCRIBE_PROVIDER=gemini.
+const geminiBase = "https://generativelanguage.googleapis.com/v1beta"
+
+// geminiChat is the Generator for Gemini's generateContent API, either on the Gemini API with
+// an API key or on Vertex AI with a service account's access token.
+type geminiChat struct {
+	name string // "Gemini" or "Vertex AI"
+
+	apiKey string // Gemini API mode
+
+	// Vertex AI mode.
+	accessToken string
+	project     string
+	location    string
+
+	// safety maps harm categories (e.g. HARM_CATEGORY_HARASSMENT) to block thresholds.
+	safety map[string]string
+}
+
+// endpoint returns the generateContent URL for model.
+func (g geminiChat) endpoint(model string) string {
+	if g.accessToken != "" {
+		return fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:generateContent",
+			g.location, neturl.PathEscape(g.project), g.location, neturl.PathEscape(model))
+	}
+	return fmt.Sprintf("%s/models/%s:generateContent", geminiBase, neturl.PathEscape(model))
+}
+
+// geminiContent is one message of a generateContent request or response.
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiPart is one part of a geminiContent message.
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// newGeminiContent builds a single-part content message.
+func newGeminiContent(role, text string) geminiContent {
+	return geminiContent{Role: role, Parts: []geminiPart{{Text: text}}}
+}
+
+// Generate performs a single generateContent call against model. System messages become the
+// system instruction, assistant turns are sent in the "model" role, and extra parameters are
+// merged into generationConfig.
+func (g geminiChat) Generate(model string, creq completionRequest) (GenerationResult, error) {
+	var system []string
+	var contents []geminiContent
+	for _, m := range creq.Messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "assistant":
+			contents = append(contents, newGeminiContent("model", m.Content))
+		default:
+			contents = append(contents, newGeminiContent("user", m.Content))
+		}
+	}
+
+	generationConfig := map[string]any{"temperature": creq.Temperature}
+	if creq.MaxTokens > 0 {
+		generationConfig["maxOutputTokens"] = creq.MaxTokens
+	}
+	if creq.TopP > 0 {
+		generationConfig["topP"] = creq.TopP
+	}
+	if creq.JSON {
+		generationConfig["responseMimeType"] = "application/json"
+	}
+	for k, v := range creq.Extra {
+		generationConfig[k] = v
+	}
+	reqBody := map[string]any{
+		"contents":         contents,
+		"generationConfig": generationConfig,
+	}
+	if len(system) > 0 {
+		reqBody["systemInstruction"] = newGeminiContent("", strings.Join(system, "\n\n"))
+	}
+	if len(g.safety) > 0 {
+		categories := make([]string, 0, len(g.safety))
+		for category := range g.safety {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		var settings []map[string]string
+		for _, category := range categories {
+			settings = append(settings, map[string]string{"category": category, "threshold": g.safety[category]})
+		}
+		reqBody["safetySettings"] = settings
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.endpoint(model), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	if g.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+g.accessToken)
+	} else {
+		req.Header.Set("x-goog-api-key", g.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return GenerationResult{}, newModelStatusError(g.name, resp, respBytes)
+	}
+
+	var result struct {
+		Candidates []struct {
+			Content      geminiContent `json:"content"`
+			FinishReason string        `json:"finishReason"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+		ModelVersion string `json:"modelVersion"`
+	}
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return GenerationResult{}, err
+	}
+	if len(result.Candidates) == 0 {
+		return GenerationResult{}, fmt.Errorf("no candidates returned from %s API (the prompt may have been blocked by safety settings)", g.name)
+	}
+	candidate := result.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	if text.Len() == 0 {
+		return GenerationResult{}, fmt.Errorf("%s API returned no text (finish reason %q)", g.name, candidate.FinishReason)
+	}
+	if result.ModelVersion == "" {
+		result.ModelVersion = model
+	}
+	return GenerationResult{
+		Content:      text.String(),
+		Model:        result.ModelVersion,
+		FinishReason: candidate.FinishReason,
+		Usage: tokenUsage{
+			PromptTokens:     result.UsageMetadata.PromptTokenCount,
+			CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      result.UsageMetadata.TotalTokenCount,
+		},
+		Truncated: candidate.FinishReason == "MAX_TOKENS",
+	}, nil
+}
+
+// parseGeminiSafety parses "CATEGORY=THRESHOLD" pairs (e.g. "HARASSMENT=BLOCK_ONLY_HIGH"),
+// adding the HARM_CATEGORY_ prefix where it is left off.
+func parseGeminiSafety(pairs []string) (map[string]string, error) {
+	safety := make(map[string]string)
+	for _, pair := range pairs {
+		category, threshold, ok := strings.Cut(pair, "=")
+		category, threshold = strings.ToUpper(strings.TrimSpace(category)), strings.ToUpper(strings.TrimSpace(threshold))
+		if !ok || category == "" || threshold == "" {
+			return nil, fmt.Errorf("invalid Gemini safety setting %q (want CATEGORY=THRESHOLD)", pair)
+		}
+		if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+			category = "HARM_CATEGORY_" + category
+		}
+		safety[category] = threshold
+	}
+	return safety, nil
+}
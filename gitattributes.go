@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// gitattributesPath is read for linguist-generated and linguist-vendored markers.
+const gitattributesPath = ".gitattributes"
+
+// linguistAttributes are the attributes that mark a file as not worth describing.
+var linguistAttributes = []string{"linguist-generated", "linguist-vendored"}
+
+// linguistRule is one .gitattributes line setting or unsetting a linguist attribute.
+type linguistRule struct {
+	pattern string
+	attr    string
+	set     bool
+}
+
+// parseLinguistRules extracts the linguist-generated and linguist-vendored settings from a
+// .gitattributes file, in file order. "attr" and "attr=true" set an attribute; "-attr",
+// "!attr" and "attr=false" unset it.
+func parseLinguistRules(gitattributes string) []linguistRule {
+	var rules []linguistRule
+	for _, line := range strings.Split(gitattributes, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			name, value, _ := strings.Cut(strings.TrimLeft(field, "-!"), "=")
+			for _, attr := range linguistAttributes {
+				if name != attr {
+					continue
+				}
+				set := field[0] != '-' && field[0] != '!' && value != "false"
+				rules = append(rules, linguistRule{pattern: fields[0], attr: attr, set: set})
+			}
+		}
+	}
+	return rules
+}
+
+// linguistExcluded returns the linguist attribute that marks path as generated or vendored,
+// or "" when none does. As in git, the last line matching path decides each attribute.
+func linguistExcluded(rules []linguistRule, path string) string {
+	state := make(map[string]bool)
+	for _, r := range rules {
+		if matchPathPattern(r.pattern, path) {
+			state[r.attr] = r.set
+		}
+	}
+	for _, attr := range linguistAttributes {
+		if state[attr] {
+			return attr
+		}
+	}
+	return ""
+}
+
+// stripLinguistFiles replaces the content of every generated or vendored file with a one-line
+// note, keeping its header and line counts so the model still knows it changed, and returns
+// the stripped paths.
+func stripLinguistFiles(diff string, rules []linguistRule) (string, []string) {
+	if len(rules) == 0 {
+		return diff, nil
+	}
+	var stripped []string
+	files := splitDiffFiles(diff)
+	for i, f := range files {
+		if f.Path == "" {
+			continue
+		}
+		attr := linguistExcluded(rules, f.Path)
+		if attr == "" {
+			continue
+		}
+		kind := strings.TrimPrefix(attr, "linguist-")
+		files[i].Text = fileHeaderLine(f) + fmt.Sprintf("(%s file changed: %s, +%d/-%d lines, omitted)\n", kind, f.Path, f.Additions, f.Deletions)
+		stripped = append(stripped, f.Path)
+	}
+	return joinDiffFiles(files), stripped
+}
+
+// readLinguistRules reads the linguist rules of the checked-out repository's .gitattributes;
+// without one there are none.
+func readLinguistRules() []linguistRule {
+	data, err := os.ReadFile(gitattributesPath)
+	if err != nil {
+		return nil
+	}
+	return parseLinguistRules(string(data))
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PullRequest is the subset of the GitHub pull request payload DiffScribe uses.
+type PullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+
+	// ChangedFiles, Additions and Deletions are GitHub's size stats for the whole PR.
+	ChangedFiles int `json:"changed_files"`
+	Additions    int `json:"additions"`
+	Deletions    int `json:"deletions"`
+
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Milestone *struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	} `json:"milestone"`
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"base"`
+}
+
+// newGitHubRequest builds a GitHub REST API request with the standard headers, encoding
+// payload as the JSON body when it is non-nil.
+func newGitHubRequest(method, url, token string, payload any) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// doGitHubJSON sends req, checks for the expected status and decodes the JSON response into
+// out (which may be nil).
+func doGitHubJSON(req *http.Request, wantStatus int, out any) error {
+	resp, err := sendRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("GitHub API %s %s returned status %d: %s", req.Method, req.URL.Path, resp.StatusCode, string(data))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// fetchPullRequest fetches the PR metadata (title, author, head/base refs, ...) as JSON.
+func fetchPullRequest(repo, prNum, token string) (*PullRequest, error) {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%s", githubAPIBase, repo, prNum)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	var pr PullRequest
+	if err := doGitHubJSON(req, http.StatusOK, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// createCheckRun records a completed "DiffScribe" check run on the PR's head commit.
+// conclusion is one of the GitHub check conclusions, e.g. "success" or "neutral".
+func createCheckRun(repo string, pr *PullRequest, conclusion, title, summary, token string) error {
+	payload := map[string]any{
+		"name":        "DiffScribe",
+		"head_sha":    pr.Head.SHA,
+		"status":      "completed",
+		"conclusion":  conclusion,
+		"details_url": pr.HTMLURL,
+		"output": map[string]string{
+			"title":   title,
+			"summary": summary,
+		},
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/check-runs", githubAPIBase, repo)
+	req, err := newGitHubRequest(http.MethodPost, url, token, payload)
+	if err != nil {
+		return err
+	}
+	return doGitHubJSON(req, http.StatusCreated, nil)
+}
+
+// Commit is a single commit of a pull request.
+type Commit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+	// Author is the GitHub account of the commit author; nil when the email is not linked to one.
+	Author *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+// fetchPathCommits lists the most recent commits on the default branch that touched path.
+func fetchPathCommits(repo, path string, limit int, token string) ([]Commit, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits?path=%s&per_page=%d", githubAPIBase, repo, neturl.QueryEscape(path), limit)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	if err := doGitHubJSON(req, http.StatusOK, &commits); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// fetchPrCommits lists the commits of a PR, following pagination (GitHub caps it at 250).
+func fetchPrCommits(repo, prNum, token string) ([]Commit, error) {
+	const perPage = 100
+	var commits []Commit
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/pulls/%s/commits?per_page=%d&page=%d", githubAPIBase, repo, prNum, perPage, page)
+		req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+		if err != nil {
+			return nil, err
+		}
+		var batch []Commit
+		if err := doGitHubJSON(req, http.StatusOK, &batch); err != nil {
+			return nil, err
+		}
+		commits = append(commits, batch...)
+		if len(batch) < perPage {
+			return commits, nil
+		}
+	}
+}
+
+// PullFile is one entry of the PR files API.
+type PullFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"` // added, removed, modified, renamed, copied, changed or unchanged
+	Additions        int    `json:"additions"`
+	Deletions        int    `json:"deletions"`
+	// Patch is the file's unified diff hunks; GitHub leaves it out for binary and very large
+	// files.
+	Patch string `json:"patch"`
+}
+
+// fetchPrFiles lists the changed files of a PR with their patches, following pagination
+// (GitHub caps it at 3000 files).
+func fetchPrFiles(repo, prNum, token string) ([]PullFile, error) {
+	const perPage = 100
+	var files []PullFile
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/pulls/%s/files?per_page=%d&page=%d", githubAPIBase, repo, prNum, perPage, page)
+		req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+		if err != nil {
+			return nil, err
+		}
+		var batch []PullFile
+		if err := doGitHubJSON(req, http.StatusOK, &batch); err != nil {
+			return nil, err
+		}
+		files = append(files, batch...)
+		if len(batch) < perPage {
+			return files, nil
+		}
+	}
+}
+
+// countMerges counts merge commits (more than one parent), e.g. merges of the base branch
+// into the PR branch.
+func countMerges(commits []Commit) int {
+	n := 0
+	for _, c := range commits {
+		if len(c.Parents) > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// countReverts counts commits whose message marks them as a revert of earlier work.
+func countReverts(commits []Commit) int {
+	n := 0
+	for _, c := range commits {
+		msg := c.Commit.Message
+		if strings.HasPrefix(msg, "Revert") || strings.Contains(msg, "This reverts commit") {
+			n++
+		}
+	}
+	return n
+}
+
+// resolveRef checks that ref (branch, tag or SHA) exists and returns its commit SHA.
+func resolveRef(repo, ref, token string) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits/%s", githubAPIBase, repo, ref)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.sha")
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ref %q not found (status %d)", ref, resp.StatusCode)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// fetchCompareDiff fetches the unified diff between two refs via the compare endpoint. sep is
+// "..." for the changes on head since its merge base with base, or ".." for the direct
+// difference between the two commits.
+func fetchCompareDiff(repo, base, sep, head, token string) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/compare/%s%s%s", githubAPIBase, repo, base, sep, head)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3.diff")
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub API returned status %d when comparing %s%s%s", resp.StatusCode, base, sep, head)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// defaultBranches caches each repository's default branch for the rest of the run.
+var defaultBranches = struct {
+	sync.Mutex
+	byRepo map[string]string
+}{byRepo: make(map[string]string)}
+
+// fetchDefaultBranch returns the repository's default branch via GET /repos/{repo}, caching
+// the answer so repeated lookups cost a single request per run.
+func fetchDefaultBranch(repo, token string) (string, error) {
+	defaultBranches.Lock()
+	defer defaultBranches.Unlock()
+	if branch, ok := defaultBranches.byRepo[repo]; ok {
+		return branch, nil
+	}
+
+	url := fmt.Sprintf("%s/repos/%s", githubAPIBase, repo)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return "", err
+	}
+	var meta struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := doGitHubJSON(req, http.StatusOK, &meta); err != nil {
+		return "", err
+	}
+	if meta.DefaultBranch == "" {
+		return "", fmt.Errorf("repository %s has no default branch", repo)
+	}
+	defaultBranches.byRepo[repo] = meta.DefaultBranch
+	return meta.DefaultBranch, nil
+}
+
+// listOpenPullRequests lists every open PR of the repository, following pagination.
+func listOpenPullRequests(repo, token string) ([]PullRequest, error) {
+	const perPage = 100
+	var prs []PullRequest
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/repos/%s/pulls?state=open&per_page=%d&page=%d", githubAPIBase, repo, perPage, page)
+		req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+		if err != nil {
+			return nil, err
+		}
+		var batch []PullRequest
+		if err := doGitHubJSON(req, http.StatusOK, &batch); err != nil {
+			return nil, err
+		}
+		prs = append(prs, batch...)
+		if len(batch) < perPage {
+			return prs, nil
+		}
+	}
+}
+
+// findOpenPullRequestForBranch returns the open PR whose head is branch of repo, or nil
+// when there is none.
+func findOpenPullRequestForBranch(repo, branch, token string) (*PullRequest, error) {
+	owner, _, _ := strings.Cut(repo, "/")
+	url := fmt.Sprintf("%s/repos/%s/pulls?state=open&head=%s", githubAPIBase, repo, neturl.QueryEscape(owner+":"+branch))
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	var prs []PullRequest
+	if err := doGitHubJSON(req, http.StatusOK, &prs); err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+// contentExists reports whether path exists in the repository's default branch, via the
+// contents API.
+func contentExists(repo, path, token string) (bool, error) {
+	return contentExistsAt(repo, path, "", token)
+}
+
+// contentExistsAt reports whether path exists at ref (a branch, tag or commit; "" for the
+// default branch), via the contents API.
+func contentExistsAt(repo, path, ref, token string) (bool, error) {
+	url := contentsURL(repo, path, ref)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := sendRequest(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("GitHub API returned status %d when checking %s", resp.StatusCode, path)
+}
+
+// contentsURL is the contents API URL of path at ref ("" for the default branch).
+func contentsURL(repo, path, ref string) string {
+	url := fmt.Sprintf("%s/repos/%s/contents/%s", githubAPIBase, repo, strings.TrimPrefix(path, "/"))
+	if ref != "" {
+		url += "?ref=" + neturl.QueryEscape(ref)
+	}
+	return url
+}
+
+// fetchFileAtRef returns the raw content of the file at path as of ref; found is false when
+// the file does not exist there.
+func fetchFileAtRef(repo, path, ref, token string) (content string, found bool, err error) {
+	req, err := newGitHubRequest(http.MethodGet, contentsURL(repo, path, ref), token, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw+json")
+	resp, err := sendRequest(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		return string(data), true, err
+	case http.StatusNotFound:
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("GitHub API returned status %d when fetching %s at %s", resp.StatusCode, path, ref)
+}
+
+// labelExists reports whether the repository has a label called name.
+func labelExists(repo, name, token string) (bool, error) {
+	url := fmt.Sprintf("%s/repos/%s/labels/%s", githubAPIBase, repo, neturl.PathEscape(name))
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := sendRequest(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("GitHub API returned status %d when checking label %s", resp.StatusCode, name)
+}
+
+// createLabel adds a label to the repository.
+func createLabel(repo, name, color, description, token string) error {
+	payload := map[string]string{"name": name, "color": color, "description": description}
+	req, err := newGitHubRequest(http.MethodPost, fmt.Sprintf("%s/repos/%s/labels", githubAPIBase, repo), token, payload)
+	if err != nil {
+		return err
+	}
+	return doGitHubJSON(req, http.StatusCreated, nil)
+}
+
+// reactionContents are the reaction names the reactions API accepts.
+var reactionContents = []string{"+1", "-1", "laugh", "confused", "heart", "hooray", "rocket", "eyes"}
+
+// addIssueReaction reacts to the PR itself (its opening post) with content. The API answers
+// 200 instead of 201 when this user already left the same reaction, so reruns never add
+// duplicates; it reports whether a new reaction was created.
+func addIssueReaction(repo, prNum, content, token string) (bool, error) {
+	url := fmt.Sprintf("%s/repos/%s/issues/%s/reactions", githubAPIBase, repo, prNum)
+	req, err := newGitHubRequest(http.MethodPost, url, token, map[string]string{"content": content})
+	if err != nil {
+		return false, err
+	}
+	resp, err := sendRequest(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusOK:
+		return false, nil
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return false, fmt.Errorf("GitHub API returned status %d when adding reaction: %s", resp.StatusCode, string(data))
+}
+
+// Issue is the subset of an issue DiffScribe reads; PullRequest is set when the number
+// belongs to a pull request.
+type Issue struct {
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+// fetchIssue fetches issue number from repo.
+func fetchIssue(repo, number, token string) (*Issue, error) {
+	url := fmt.Sprintf("%s/repos/%s/issues/%s", githubAPIBase, repo, number)
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	var issue Issue
+	if err := doGitHubJSON(req, http.StatusOK, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// IssueComment is the subset of an issue or PR comment DiffScribe reads.
+type IssueComment struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// fetchIssueComments lists the PR's comments updated at or after since (up to 100).
+func fetchIssueComments(repo, prNum string, since time.Time, token string) ([]IssueComment, error) {
+	url := fmt.Sprintf("%s/repos/%s/issues/%s/comments?per_page=100&since=%s",
+		githubAPIBase, repo, prNum, neturl.QueryEscape(since.UTC().Format(time.RFC3339)))
+	req, err := newGitHubRequest(http.MethodGet, url, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	var comments []IssueComment
+	if err := doGitHubJSON(req, http.StatusOK, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
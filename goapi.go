@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxAPIDeltaFiles caps the Go files whose base and head versions are fetched and parsed.
+const maxAPIDeltaFiles = 30
+
+// defaultAPIDeltaBudget caps the bytes of the API delta added to the prompt.
+const defaultAPIDeltaBudget = 3000
+
+// apiChange is an exported declaration whose signature differs between base and head.
+type apiChange struct {
+	Old, New string
+}
+
+// packageDelta is the exported API a PR adds, removes or changes in one package directory.
+type packageDelta struct {
+	Dir     string
+	New     bool
+	Added   []string
+	Removed []string
+	Changed []apiChange
+}
+
+// goAPIFile is a changed Go file and the paths of its base and head versions; either is ""
+// when the file was added or deleted.
+type goAPIFile struct {
+	Dir        string
+	Base, Head string
+}
+
+// goAPIFiles returns the changed non-test Go files outside vendor directories, at most
+// maxAPIDeltaFiles of them. Files whose old or new path is hidden from the model are
+// skipped, so their contents are never fetched.
+func goAPIFiles(diff string, cfg Config) []goAPIFile {
+	hidden := hiddenFromModel(cfg)
+	var files []goAPIFile
+	for _, f := range splitDiffFiles(diff) {
+		p := f.Path
+		if p == "" || path.Ext(p) != ".go" || strings.HasSuffix(p, "_test.go") || strings.HasPrefix(p, "vendor/") || strings.Contains(p, "/vendor/") {
+			continue
+		}
+		if hidden(p) || (f.OldPath != "" && hidden(f.OldPath)) {
+			continue
+		}
+		header := f.Text
+		if h := strings.Index(header, "\n@@"); h >= 0 {
+			header = header[:h]
+		}
+		file := goAPIFile{Dir: path.Dir(p), Base: p, Head: p}
+		if f.OldPath != "" {
+			file.Base = f.OldPath
+		}
+		switch {
+		case strings.Contains(header, "\nnew file mode"):
+			file.Base = ""
+		case strings.Contains(header, "\ndeleted file mode"):
+			file.Head = ""
+		}
+		if len(files) == maxAPIDeltaFiles {
+			break
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// hiddenFromModel returns a check for paths whose contents must not reach the model: those
+// matched by DIFFSCRIBE_REDACT_PATHS or DIFFSCRIBE_IGNORE_FILE and, with
+// DIFFSCRIBE_SKIP_LINGUIST, linguist-generated or vendored files.
+func hiddenFromModel(cfg Config) func(p string) bool {
+	ignore := readIgnoreFile(cfg.IgnoreFile)
+	var linguist []linguistRule
+	if cfg.SkipLinguist {
+		linguist = readLinguistRules()
+	}
+	return func(p string) bool {
+		for _, glob := range cfg.RedactPaths {
+			if matchPathPattern(glob, p) {
+				return true
+			}
+		}
+		return isIgnored(ignore, p) || linguistExcluded(linguist, p) != ""
+	}
+}
+
+// goAPIDelta fetches the base and head versions of the changed Go files, parses them and
+// compares their exported declarations package by package. A package whose directory does
+// not exist at the base commit is reported as new. Package main is skipped, as nothing can
+// import it.
+func goAPIDelta(rc *runContext, diff string) ([]packageDelta, error) {
+	files := goAPIFiles(diff, rc.cfg)
+	if len(files) == 0 {
+		return nil, nil
+	}
+	pr, err := rc.pullRequest()
+	if err != nil {
+		return nil, err
+	}
+	cfg := rc.cfg
+	fetch := func(p, ref string) (string, error) {
+		if p == "" {
+			return "", nil
+		}
+		content, _, err := fetchFileAtRef(cfg.Repository, p, ref, cfg.GitHubToken)
+		return content, err
+	}
+
+	type dirDecls struct {
+		base, head map[string]string
+		added      bool
+	}
+	dirs := make(map[string]*dirDecls)
+	var order []string
+	for _, f := range files {
+		d := dirs[f.Dir]
+		if d == nil {
+			d = &dirDecls{base: map[string]string{}, head: map[string]string{}, added: true}
+			dirs[f.Dir] = d
+			order = append(order, f.Dir)
+		}
+		d.added = d.added && f.Base == ""
+		baseSrc, err := fetch(f.Base, pr.Base.SHA)
+		if err != nil {
+			return nil, err
+		}
+		headSrc, err := fetch(f.Head, pr.Head.SHA)
+		if err != nil {
+			return nil, err
+		}
+		exportedDecls(f.Base, baseSrc, d.base)
+		exportedDecls(f.Head, headSrc, d.head)
+	}
+
+	var deltas []packageDelta
+	sort.Strings(order)
+	for _, dir := range order {
+		d := dirs[dir]
+		delta := packageDelta{Dir: dir}
+		for key, sig := range d.head {
+			old, ok := d.base[key]
+			switch {
+			case !ok:
+				delta.Added = append(delta.Added, sig)
+			case old != sig:
+				delta.Changed = append(delta.Changed, apiChange{Old: old, New: sig})
+			}
+		}
+		for key, sig := range d.base {
+			if _, ok := d.head[key]; !ok {
+				delta.Removed = append(delta.Removed, sig)
+			}
+		}
+		if len(delta.Added)+len(delta.Removed)+len(delta.Changed) == 0 {
+			continue
+		}
+		if d.added {
+			exists, err := contentExistsAt(cfg.Repository, dir, pr.Base.SHA, cfg.GitHubToken)
+			if err != nil {
+				return nil, err
+			}
+			delta.New = !exists
+		}
+		sort.Strings(delta.Added)
+		sort.Strings(delta.Removed)
+		sort.Slice(delta.Changed, func(i, j int) bool { return delta.Changed[i].New < delta.Changed[j].New })
+		deltas = append(deltas, delta)
+	}
+	return deltas, nil
+}
+
+// exportedDecls adds the exported declarations of a Go source file to decls, keyed by name
+// ("Recv.Name" for methods) with their one-line signature as value. Sources that are empty,
+// do not parse or belong to package main add nothing.
+func exportedDecls(filename, src string, decls map[string]string) {
+	if src == "" {
+		return
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil || file.Name.Name == "main" {
+		return
+	}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			key := decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				recv := receiverName(decl.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				key = recv + "." + key
+			}
+			decl.Doc, decl.Body = nil, nil
+			decls[key] = printNode(fset, decl)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !spec.Name.IsExported() {
+						continue
+					}
+					spec.Doc, spec.Comment = nil, nil
+					spec.Type = exportedShape(spec.Type)
+					decls[spec.Name.Name] = "type " + printNode(fset, spec)
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						sig := decl.Tok.String() + " " + name.Name
+						if spec.Type != nil {
+							sig += " " + printNode(fset, spec.Type)
+						}
+						decls[name.Name] = sig
+					}
+				}
+			}
+		}
+	}
+}
+
+// receiverName returns the type name of a method receiver expression such as "*Client" or
+// "List[T]".
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// exportedShape drops the unexported fields of a struct and the unexported methods of an
+// interface, which are not part of the type's API.
+func exportedShape(expr ast.Expr) ast.Expr {
+	var fields *ast.FieldList
+	switch t := expr.(type) {
+	case *ast.StructType:
+		fields = t.Fields
+	case *ast.InterfaceType:
+		fields = t.Methods
+	default:
+		return expr
+	}
+	var kept []*ast.Field
+	for _, field := range fields.List {
+		field.Doc, field.Comment, field.Tag = nil, nil, nil
+		if len(field.Names) == 0 {
+			if name := receiverName(field.Type); name == "" || ast.IsExported(name) {
+				kept = append(kept, field)
+			}
+			continue
+		}
+		var names []*ast.Ident
+		for _, name := range field.Names {
+			if name.IsExported() {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			field.Names = names
+			kept = append(kept, field)
+		}
+	}
+	fields.List = kept
+	return expr
+}
+
+// printNode renders node as Go source on one line, separating struct fields and interface
+// methods with semicolons.
+func printNode(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(buf.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if b.Len() > 0 && line != "" {
+			if prev := b.String(); strings.HasSuffix(prev, "{") || strings.HasSuffix(prev, "(") || strings.HasSuffix(prev, ",") ||
+				strings.HasPrefix(line, "}") || strings.HasPrefix(line, ")") {
+				b.WriteString(" ")
+			} else {
+				b.WriteString("; ")
+			}
+		}
+		b.WriteString(line)
+	}
+	return strings.NewReplacer("( ", "(", ", )", ")").Replace(b.String())
+}
+
+// formatAPIDelta renders one bullet per change, e.g.
+// "- `pkg/cache`: changed `func Get(key string) V` → `func Get(ctx context.Context, key string) V`".
+// A new package gets a single bullet naming its exported declarations. Lines beyond budget
+// bytes are only counted.
+func formatAPIDelta(deltas []packageDelta, budget int) string {
+	var lines []string
+	for _, d := range deltas {
+		if d.New {
+			lines = append(lines, fmt.Sprintf("- new package `%s`: `%s`\n", d.Dir, strings.Join(d.Added, "`, `")))
+			continue
+		}
+		for _, sig := range d.Added {
+			lines = append(lines, fmt.Sprintf("- `%s`: added `%s`\n", d.Dir, sig))
+		}
+		for _, c := range d.Changed {
+			lines = append(lines, fmt.Sprintf("- `%s`: changed `%s` → `%s`\n", d.Dir, c.Old, c.New))
+		}
+		for _, sig := range d.Removed {
+			lines = append(lines, fmt.Sprintf("- `%s`: removed `%s`\n", d.Dir, sig))
+		}
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if b.Len()+len(line) > budget {
+			fmt.Fprintf(&b, "- ... (%d more change(s))\n", len(lines)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// apiDeltaHeading is the heading of the appended API delta.
+const apiDeltaHeading = "## API changes"
+
+// appendAPIDelta appends list under "## API changes" unless the body already has such a
+// section, so reruns never duplicate it.
+func appendAPIDelta(body, list string) string {
+	if list == "" {
+		return body
+	}
+	for _, s := range splitSections(body) {
+		if sectionKey(s.Title()) == sectionKey(strings.TrimLeft(apiDeltaHeading, "# ")) {
+			return body
+		}
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + apiDeltaHeading + "\n" + list
+}
+
+// changedAPI computes the Go API delta of diff when the prompt (DIFFSCRIBE_GO_API_DELTA) or
+// the description (DIFFSCRIBE_GO_API_SECTION) uses it. A failure is logged and yields no
+// delta.
+func changedAPI(rc *runContext, diff string) []packageDelta {
+	if !rc.cfg.GoAPIDelta && !rc.cfg.GoAPISection {
+		return nil
+	}
+	deltas, err := goAPIDelta(rc, diff)
+	if err != nil {
+		log.Printf("Warning: failed to compute the Go API delta: %v", err)
+	}
+	return deltas
+}
+
+// apiDeltaPart lists the exported API changes for the prompt with DIFFSCRIBE_GO_API_DELTA.
+func apiDeltaPart(cfg Config, deltas []packageDelta) promptPart {
+	if !cfg.GoAPIDelta || len(deltas) == 0 {
+		return promptPart{}
+	}
+	log.Printf("Found exported API changes in %d Go package(s)", len(deltas))
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Go API Changes", Text: formatAPIDelta(deltas, defaultAPIDeltaBudget)},
+		Instruction:  "Describe the exported API changes listed above precisely, and call out removed or changed signatures as potentially breaking for callers.",
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGoAPIFilesSkipsHiddenPaths(t *testing.T) {
+	ignoreFile := filepath.Join(t.TempDir(), ".diffscribeignore")
+	if err := os.WriteFile(ignoreFile, []byte("gen/**\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{RedactPaths: []string{"internal/secret/**"}, IgnoreFile: ignoreFile}
+
+	diff := `diff --git a/api/client.go b/api/client.go
+--- a/api/client.go
++++ b/api/client.go
+@@ -1 +1 @@
+-package api
++package api // client
+diff --git a/internal/secret/keys.go b/internal/secret/keys.go
+--- a/internal/secret/keys.go
++++ b/internal/secret/keys.go
+@@ -1 +1 @@
+-package secret
++package secret // keys
+diff --git a/internal/secret/old.go b/pkg/moved.go
+similarity index 90%
+rename from internal/secret/old.go
+rename to pkg/moved.go
+--- a/internal/secret/old.go
++++ b/pkg/moved.go
+@@ -1 +1 @@
+-package secret
++package pkg
+diff --git a/gen/models.go b/gen/models.go
+new file mode 100644
+--- /dev/null
++++ b/gen/models.go
+@@ -0,0 +1 @@
++package gen
+diff --git a/api/client_test.go b/api/client_test.go
+--- a/api/client_test.go
++++ b/api/client_test.go
+@@ -1 +1 @@
+-package api
++package api // test
+`
+	want := []goAPIFile{{Dir: "api", Base: "api/client.go", Head: "api/client.go"}}
+	if got := goAPIFiles(diff, cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("goAPIFiles = %+v, want %+v", got, want)
+	}
+}
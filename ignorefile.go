@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is one line of a .diffscribeignore file.
+type ignoreRule struct {
+	pattern string
+	re      *regexp.Regexp // pattern compiled by compilePathPattern
+	negate  bool           // a "!pattern" line re-includes paths an earlier line excluded
+}
+
+// parseIgnoreFile parses gitignore-style lines, skipping blanks and "#" comments, and compiles
+// each pattern once.
+func parseIgnoreFile(content string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{pattern: line}
+		if strings.HasPrefix(line, "!") {
+			rule = ignoreRule{pattern: line[1:], negate: true}
+		}
+		if rule.pattern != "" {
+			rule.re = compilePathPattern(rule.pattern)
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// isIgnored reports whether path is excluded by rules; as in gitignore, the last matching
+// line wins.
+func isIgnored(rules []ignoreRule, path string) bool {
+	ignored := false
+	path = strings.TrimPrefix(path, "/")
+	for _, r := range rules {
+		if r.re.MatchString(path) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// excludeIgnoredFiles drops the blocks of every file whose path (old or new) is ignored by
+// rules and returns the dropped paths.
+func excludeIgnoredFiles(diff string, rules []ignoreRule) (string, []string) {
+	if len(rules) == 0 {
+		return diff, nil
+	}
+	var kept []fileDiff
+	var dropped []string
+	for _, f := range splitDiffFiles(diff) {
+		if f.Path != "" && (isIgnored(rules, f.Path) || f.OldPath != "" && f.OldPath != f.Path && isIgnored(rules, f.OldPath)) {
+			dropped = append(dropped, f.Path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return joinDiffFiles(kept), dropped
+}
+
+// readIgnoreFile reads the ignore rules at path; a missing file has none.
+func readIgnoreFile(path string) []ignoreRule {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseIgnoreFile(string(data))
+}
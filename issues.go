@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxLinkedIssues caps how many referenced issues are fetched for the prompt.
+const maxLinkedIssues = 5
+
+// maxIssueBodyBytes bounds how much of each issue body is kept.
+const maxIssueBodyBytes = 1500
+
+// issueRefPattern matches GitHub issue references in a PR body: "#12", "owner/repo#12" and
+// issue URLs. Submatches are the repository (empty for this one) and the number.
+var issueRefPattern = regexp.MustCompile(`(?:\b([\w.-]+/[\w.-]+))?#(\d+)\b|github\.com/([\w.-]+/[\w.-]+)/issues/(\d+)`)
+
+// branchIssuePattern matches an issue number leading a branch name segment, as in
+// "123-fix-login", "fix/123-login" or "issue-123".
+var branchIssuePattern = regexp.MustCompile(`(?i)(?:^|/)(?:issues?|gh)?[-_]?(\d+)(?:[-_/]|$)`)
+
+// jiraKeyPattern matches Jira issue keys such as "ABC-456".
+var jiraKeyPattern = regexp.MustCompile(`\b([A-Z][A-Z0-9]{1,9}-\d+)\b`)
+
+// linkedIssue is an issue the PR references, fetched for the prompt.
+type linkedIssue struct {
+	Ref   string // "#12", "owner/repo#12" or "ABC-456"
+	Title string
+	Body  string
+}
+
+// issueRef is a GitHub issue reference; Repo is empty for the PR's own repository.
+type issueRef struct {
+	Repo   string
+	Number int
+}
+
+// findIssueRefs returns the GitHub issue references and, when jira is set, the Jira keys in
+// the PR body and branch name, in order of first mention with the body first.
+func findIssueRefs(body, branch string, jira bool) ([]issueRef, []string) {
+	var refs []issueRef
+	seen := make(map[issueRef]bool)
+	add := func(repo, number string) {
+		n, err := strconv.Atoi(number)
+		if err != nil || n == 0 {
+			return
+		}
+		ref := issueRef{Repo: repo, Number: n}
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	for _, m := range issueRefPattern.FindAllStringSubmatch(body, -1) {
+		if m[2] != "" {
+			add(m[1], m[2])
+		} else {
+			add(m[3], m[4])
+		}
+	}
+	for _, m := range branchIssuePattern.FindAllStringSubmatch(branch, -1) {
+		add("", m[1])
+	}
+
+	var keys []string
+	if jira {
+		for _, key := range append(jiraKeyPattern.FindAllString(body, -1), jiraKeyPattern.FindAllString(strings.ToUpper(branch), -1)...) {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return refs, keys
+}
+
+// resolveLinkedIssues fetches the issues the PR body and branch reference, up to
+// maxLinkedIssues. References to pull requests, including this one, are skipped, as are
+// issues that cannot be fetched and, unless cfg.CrossRepoIssues is set, issues of other
+// repositories.
+func resolveLinkedIssues(cfg Config, body, branch string) []linkedIssue {
+	refs, keys := findIssueRefs(body, branch, cfg.JiraURL != "")
+	var issues []linkedIssue
+	for _, ref := range refs {
+		if len(issues) == maxLinkedIssues {
+			return issues
+		}
+		repo, label, ok := issueRepo(cfg, ref)
+		if !ok {
+			log.Printf("Skipping linked issue %s in another repository (set DIFFSCRIBE_CROSS_REPO_ISSUES to include it)", label)
+			continue
+		}
+		issue, err := fetchIssue(repo, strconv.Itoa(ref.Number), cfg.GitHubToken)
+		if err != nil {
+			log.Printf("Warning: failed to fetch linked issue %s: %v", label, err)
+			continue
+		}
+		if issue.PullRequest != nil {
+			continue
+		}
+		issues = append(issues, linkedIssue{Ref: label, Title: issue.Title, Body: issue.Body})
+	}
+	for _, key := range keys {
+		if len(issues) == maxLinkedIssues {
+			break
+		}
+		issue, found, err := fetchJiraIssue(cfg, key)
+		if err != nil {
+			log.Printf("Warning: failed to fetch Jira issue %s: %v", key, err)
+			continue
+		}
+		if found {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// issueRepo returns the repository and prompt label of ref, and whether it may be fetched:
+// references to other repositories only with cfg.CrossRepoIssues.
+func issueRepo(cfg Config, ref issueRef) (string, string, bool) {
+	label := fmt.Sprintf("#%d", ref.Number)
+	if ref.Repo == "" || strings.EqualFold(ref.Repo, cfg.Repository) {
+		return cfg.Repository, label, true
+	}
+	return ref.Repo, ref.Repo + label, cfg.CrossRepoIssues
+}
+
+// jiraIssue is the subset of a Jira REST API v2 issue DiffScribe reads.
+type jiraIssue struct {
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+	} `json:"fields"`
+}
+
+// fetchJiraIssue fetches key from DIFFSCRIBE_JIRA_URL. It authenticates with basic auth when
+// DIFFSCRIBE_JIRA_USER is set (Jira Cloud API tokens) and with a bearer token otherwise
+// (Data Center personal access tokens). found is false when the key is not an issue there,
+// which is common for look-alikes such as "UTF-8".
+func fetchJiraIssue(cfg Config, key string) (issue linkedIssue, found bool, err error) {
+	url := strings.TrimRight(cfg.JiraURL, "/") + "/rest/api/2/issue/" + key + "?fields=summary,description"
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return issue, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case cfg.JiraUser != "":
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(cfg.JiraUser+":"+cfg.JiraToken)))
+	case cfg.JiraToken != "":
+		req.Header.Set("Authorization", "Bearer "+cfg.JiraToken)
+	}
+	resp, err := sendRequest(req)
+	if err != nil {
+		return issue, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return issue, false, nil
+	default:
+		return issue, false, fmt.Errorf("Jira returned status %d", resp.StatusCode)
+	}
+	var ji jiraIssue
+	if err := json.NewDecoder(resp.Body).Decode(&ji); err != nil {
+		return issue, false, err
+	}
+	return linkedIssue{Ref: key, Title: ji.Fields.Summary, Body: ji.Fields.Description}, true, nil
+}
+
+// formatLinkedIssues renders each issue as its reference and title, followed by its body
+// without HTML comments and cut to maxIssueBodyBytes at a line boundary.
+func formatLinkedIssues(issues []linkedIssue) string {
+	var b strings.Builder
+	for i, issue := range issues {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %s\n", issue.Ref, strings.TrimSpace(issue.Title))
+		body := strings.TrimSpace(htmlCommentPattern.ReplaceAllString(strings.ReplaceAll(issue.Body, "\r\n", "\n"), ""))
+		if body == "" {
+			continue
+		}
+		used := 0
+		for _, line := range strings.Split(body, "\n") {
+			line = strings.TrimRight(line, " \t")
+			if used+len(line) > maxIssueBodyBytes {
+				b.WriteString("...\n")
+				break
+			}
+			b.WriteString(line + "\n")
+			used += len(line) + 1
+		}
+	}
+	return b.String()
+}
+
+// linkedIssuesPart adds the issues the PR body or branch name references to the prompt with
+// DIFFSCRIBE_LINKED_ISSUES.
+func linkedIssuesPart(rc *runContext) promptPart {
+	if !rc.cfg.LinkedIssues {
+		return promptPart{}
+	}
+	branch := ""
+	if pr, err := rc.pullRequest(); err != nil {
+		log.Printf("Warning: failed to fetch the PR branch for linked issues: %v", err)
+	} else {
+		branch = pr.Head.Ref
+	}
+	issues := resolveLinkedIssues(rc.cfg, rc.cfg.PRBody, branch)
+	if len(issues) == 0 {
+		return promptPart{}
+	}
+	log.Printf("Including %d linked issue(s) as prompt context", len(issues))
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Linked Issues", Text: formatLinkedIssues(issues)},
+		Instruction:  "Explain how the change addresses the linked issues above, but describe only what the diff actually does; do not claim an issue is fully resolved unless the diff shows it.",
+	}
+}
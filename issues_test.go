@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestIssueRepo(t *testing.T) {
+	tests := []struct {
+		name       string
+		ref        issueRef
+		crossRepo  bool
+		repo, want string
+		ok         bool
+	}{
+		{name: "same repository", ref: issueRef{Number: 12}, repo: "acme/app", want: "#12", ok: true},
+		{name: "same repository by name", ref: issueRef{Repo: "Acme/App", Number: 12}, repo: "acme/app", want: "#12", ok: true},
+		{name: "other repository", ref: issueRef{Repo: "acme/private", Number: 3}, repo: "acme/private", want: "acme/private#3"},
+		{name: "other repository opted in", ref: issueRef{Repo: "acme/private", Number: 3}, crossRepo: true, repo: "acme/private", want: "acme/private#3", ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, label, ok := issueRepo(Config{Repository: "acme/app", CrossRepoIssues: tt.crossRepo}, tt.ref)
+			if repo != tt.repo || label != tt.want || ok != tt.ok {
+				t.Errorf("issueRepo = %q, %q, %v; want %q, %q, %v", repo, label, ok, tt.repo, tt.want, tt.ok)
+			}
+		})
+	}
+}
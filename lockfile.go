@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// lockfileNames are the dependency lockfiles whose diffs are collapsed to a summary line;
+// their hash and resolution lines say little about the change and cost many tokens.
+var lockfileNames = map[string]bool{
+	"package-lock.json": true, "npm-shrinkwrap.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"bun.lockb": true, "go.sum": true, "cargo.lock": true, "gemfile.lock": true, "poetry.lock": true,
+	"pipfile.lock": true, "uv.lock": true, "composer.lock": true, "mix.lock": true, "flake.lock": true,
+	"podfile.lock": true, "pubspec.lock": true, "packages.lock.json": true, "gradle.lockfile": true,
+}
+
+// isLockfile reports whether p names a dependency lockfile.
+func isLockfile(p string) bool {
+	return lockfileNames[strings.ToLower(path.Base(p))]
+}
+
+// collapseLockfiles replaces the content of every lockfile with a one-line summary such as
+// "(dependency lockfile updated, +120/-80 lines)", keeping its header, and returns how many
+// it collapsed. Manifest changes are still described by DIFFSCRIBE_DEPENDENCY_CHANGES.
+func collapseLockfiles(diff string) (string, int) {
+	files := splitDiffFiles(diff)
+	n := 0
+	for i, f := range files {
+		if f.Path == "" || !isLockfile(f.Path) {
+			continue
+		}
+		files[i].Text = fileHeaderLine(f) + fmt.Sprintf("(dependency lockfile updated, +%d/-%d lines)\n", f.Additions, f.Deletions)
+		n++
+	}
+	if n == 0 {
+		return diff, 0
+	}
+	return joinDiffFiles(files), n
+}
@@ -3,80 +3,484 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 const (
 	githubAPIBase            = "https://api.github.com"
 	githubModelsBase         = "https://models.inference.ai.azure.com"
-	maxDiffSize              = 8000
+	minDiffSize              = 1000 // diff bytes kept however tight the prompt budget is
 	unfilledCommentThreshold = 3
 )
 
+// commentFooter ends every comment DiffScribe posts; main sets it from the provider and model.
+var commentFooter = poweredBy(defaultProvider, providerModels[defaultProvider])
+
+// poweredBy formats the comment footer crediting provider and model.
+func poweredBy(provider, model string) string {
+	return fmt.Sprintf("---\n*Powered by [DiffScribe](https://github.com/DiffScribe) using %s (%s)*", providerLabels[provider], model)
+}
+
 func main() {
-	token := os.Getenv("GITHUB_TOKEN")
-	repository := os.Getenv("GITHUB_REPOSITORY")
-	prNumber := os.Getenv("PR_NUMBER")
-	prBody := os.Getenv("PR_BODY")
+	allOpen := flag.Bool("all-open", false, "process every open PR in the repository instead of PR_NUMBER")
+	failFast := flag.Bool("fail-fast", false, "with --all-open, stop at the first PR that fails")
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	apiLimiter = newRateLimiter(cfg.RPM, cfg.RPMBurst)
+	rateLimitRetries = cfg.RateLimitRetries
+	if modelChain, err = newModelChain(cfg); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	primaryModel = modelChain[0].model
+	commentFooter = poweredBy(cfg.Provider, primaryModel)
+	streamResponses, streamIdleTimeout = cfg.Stream, cfg.StreamIdleTimeout
+	maxAttempts = cfg.MaxAttempts
+	systemPersona = strings.ReplaceAll(cfg.SystemPrompt, `\n`, "\n")
+	if err := loadPromptFiles(cfg.PromptFile, cfg.SystemPromptFile); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	customPrices = cfg.ModelPrices
+	messageRoles = roleConfig{System: cfg.SystemRole, User: cfg.UserRole, MergeSystem: cfg.MergeSystem}
+	redactInput = cfg.RedactInput
+	if cfg.SafeMode {
+		log.Println("Safe mode: PR body editing is disabled by policy; publishing to comments only.")
+	}
+
+	if repoDisabled(cfg) {
+		log.Printf("DiffScribe is disabled for this repository (%s exists). Exiting without changes.", cfg.DisabledPath)
+		return
+	}
 
-	if token == "" || repository == "" || prNumber == "" {
-		log.Fatal("Required environment variables (GITHUB_TOKEN, GITHUB_REPOSITORY, PR_NUMBER) are not set.")
+	if *allOpen {
+		results, err := runBatch(cfg, *failFast, run)
+		reportMetrics(cfg.MetricsFile)
+		if err != nil {
+			log.Fatalf("DiffScribe failed: %v", err)
+		}
+		summary := summarizeBatch(results)
+		log.Println(summary)
+		os.Exit(summary.ExitCode())
 	}
 
-	templateBytes, err := os.ReadFile(".github/pull_request_template.md")
+	if cfg.PRNumber == "" {
+		log.Fatal("Invalid configuration: PR_NUMBER is not set (or pass --all-open)")
+	}
+	err = handleEvent(cfg)
+	reportMetrics(cfg.MetricsFile)
 	if err != nil {
-		log.Fatalf("Failed to read PR template: %v", err)
+		log.Fatalf("DiffScribe failed: %v", err)
 	}
-	template := string(templateBytes)
+}
 
-	if !isTemplateUnfilled(prBody, template) {
-		log.Println("PR description appears to be already filled. Skipping DiffScribe.")
-		return
+// repoDisabled reports whether the repository opted out by committing the marker file at
+// DIFFSCRIBE_DISABLED_PATH. The local checkout is checked first; without one (e.g. in batch
+// mode) the contents API is asked instead.
+func repoDisabled(cfg Config) bool {
+	if cfg.DisabledPath == "" {
+		return false
+	}
+	if _, err := os.Stat(cfg.DisabledPath); err == nil {
+		return true
+	}
+	if _, err := os.Stat(".git"); err == nil {
+		return false
+	}
+	exists, err := contentExists(cfg.Repository, cfg.DisabledPath, cfg.GitHubToken)
+	if err != nil {
+		log.Printf("Warning: failed to check for %s: %v", cfg.DisabledPath, err)
+		return false
+	}
+	return exists
+}
+
+// run executes one DiffScribe pass over the configured PR and reports whether it filled the
+// description, skipped the PR or failed.
+func run(cfg Config) (runOutcome, error) {
+	rc := &runContext{cfg: cfg}
+	runUsage := usage.Snapshot()
+
+	if skip, err := authorOrTitleSkipped(rc); err != nil {
+		return outcomeFailed, err
+	} else if skip {
+		return outcomeSkipped, nil
+	}
+
+	stopTemplate := timings.Start("template")
+	template, err := loadTemplate(cfg.DefaultTemplate)
+	stopTemplate()
+	if err != nil {
+		return outcomeFailed, err
+	}
+	if prSkipped(rc, template) {
+		return outcomeSkipped, nil
 	}
 
 	log.Println("PR description is unfilled. Posting notice comment...")
-	if err := postUnfilledNotice(repository, prNumber, token); err != nil {
+	onboarding := ""
+	if cfg.Onboarding && firstRunInRepo(cfg) {
+		log.Println("First DiffScribe run in this repository; adding the onboarding note.")
+		onboarding = onboardingBlurb
+	}
+	if err := postUnfilledNotice(cfg.Repository, cfg.PRNumber, cfg.GitHubToken, onboarding); err != nil {
 		log.Printf("Warning: failed to post unfilled notice: %v", err)
+	} else if onboarding != "" {
+		markOnboarded(cfg)
 	}
 
 	log.Println("Fetching PR diff...")
 
-	diff, err := fetchPrDiff(repository, prNumber, token)
+	stopDiff := timings.Start("diff")
+	diff, err := fetchDiff(rc)
+	stopDiff()
 	if err != nil {
-		log.Fatalf("Failed to fetch PR diff: %v", err)
+		return outcomeFailed, fmt.Errorf("failed to fetch PR diff: %w", err)
 	}
 	log.Printf("Fetched diff: %d chars", len(diff))
+	diff = redactPaths(diff, cfg.RedactPaths)
+	fullDiff := diff
+	rc.changedPaths = changedFiles(diff)
+	post := PostContext{
+		Config:      cfg,
+		Template:    template,
+		PRBody:      cfg.PRBody,
+		FileChanges: parseFileChanges(fullDiff),
+	}
+	if cfg.DependencyChanges {
+		post.DependencyChanges = extractDependencyChanges(fullDiff)
+	}
+
+	in := PromptInput{Template: template, CurrentBody: trimCurrentBody(cfg.PRBody, cfg.BodyBudget)}
+	if pr, err := rc.pullRequest(); err != nil {
+		log.Printf("Warning: failed to fetch the PR title and branch for the prompt: %v", err)
+	} else {
+		in.Title, in.Branch = pr.Title, pr.Head.Ref
+	}
+	// Each feature contributes its own context block and instruction, in prompt order; the
+	// parsed results the post-processors reuse are kept in post.
+	moves, diff := movesPart(cfg, filterPromptDiff(diff, cfg))
+	post.Symbols = changedSymbols(cfg, diff)
+	post.APIDelta = changedAPI(rc, fullDiff)
+	post.Packages = monorepoPackages(cfg, diff)
+	post.Parent = stackParent(rc)
+	in.addParts(
+		moves,
+		symbolsPart(cfg, post.Symbols),
+		apiDeltaPart(cfg, post.APIDelta),
+		fileTreePart(cfg, post.FileChanges),
+		classificationPart(cfg, post.FileChanges),
+		commitMessagesPart(rc),
+		packagesPart(post.Packages),
+		repoContextPart(cfg),
+		parentPart(post.Parent),
+		linkedIssuesPart(rc),
+	)
+	addOutputInstructions(cfg, &in)
+	in.addParts(netDiffPart(rc), milestonePart(rc))
+
+	diff = fitPrompt(rc, &in, diff, fullDiff)
+	post.Truncated = rc.truncated
+
+	description, err := fillDescription(rc, in, template, fullDiff)
+	if err != nil {
+		return outcomeFailed, err
+	}
+	if cfg.ReleaseNote {
+		post.ReleaseNote = newReleaseNoteMemo(rc.cache, rc.cacheKey)
+	}
+	post.FileSummaries = changedFileSummaries(rc, diff)
+	postProcess := func(description string) string {
+		pc := post
+		pc.Description = description
+		return runPostProcessors(pc, cfg.PostProcessors)
+	}
+	stopPost := timings.Start("post")
+	description = postProcess(description)
+	stopPost()
+	log.Printf("Description generated: %d chars", len(description))
+
+	postComparison(rc, in, description, postProcess)
 
-	if len(diff) > maxDiffSize {
-		diff = diff[:maxDiffSize] + "\n\n... (diff truncated to fit context window)"
-		log.Printf("Diff truncated to %d chars", maxDiffSize)
+	rc.description = description
+	rc.filledSections = countFilledSections(description, template, cfg.HeadingSynonyms)
+	log.Printf("Sections filled from the diff: %d", rc.filledSections)
+
+	rc.addNotes(
+		summaryNote(rc, template),
+		stackNote(rc),
+		codeownersNote(rc),
+		blameNote(rc),
+		qualityNote(rc, template),
+	)
+
+	if cfg.SquashMessage {
+		if err := suggestSquashMessage(cfg, diff); err != nil {
+			log.Printf("Warning: failed to suggest squash commit message: %v", err)
+		}
+	}
+
+	if spent := usage.Since(runUsage); len(spent) > 0 {
+		log.Printf("Usage: %s", spent)
+		if cfg.UsageFooter {
+			rc.addNotes("<sub>Usage: " + spent.String() + "</sub>")
+		}
 	}
 
-	log.Println("Calling GitHub Models API (gpt-4o-mini) to fill PR description...")
-	filledDescription, err := generateDescription(template, prBody, diff, token)
+	if errs := runOutputs(rc, cfg.Outputs); len(errs) > 0 {
+		return outcomeFailed, fmt.Errorf("%d of %d output target(s) failed: %w", len(errs), len(cfg.Outputs), errors.Join(errs...))
+	}
+	if cfg.React {
+		if created, err := addIssueReaction(cfg.Repository, cfg.PRNumber, cfg.Reaction, cfg.GitHubToken); err != nil {
+			log.Printf("Warning: failed to add %q reaction: %v", cfg.Reaction, err)
+		} else if !created {
+			log.Printf("PR already has the %q reaction from DiffScribe.", cfg.Reaction)
+		}
+	}
+	log.Println("DiffScribe completed successfully.")
+	return outcomeSucceeded, nil
+}
+
+// fillDescription generates the description for in. An empty diff, or with
+// DIFFSCRIBE_DETERMINISTIC_FALLBACK a failed or empty generation, gets the deterministic
+// description instead.
+func fillDescription(rc *runContext, in PromptInput, template, fullDiff string) (string, error) {
+	cfg := rc.cfg
+	if strings.TrimSpace(fullDiff) == "" && cfg.DeterministicFallback {
+		log.Println("The diff is empty; writing a deterministic description without the model.")
+		return buildDeterministicDescription(template, fullDiff), nil
+	}
+	log.Printf("Calling %s (%s) to fill PR description...", cfg.Provider, primaryModel)
+	stopGen := timings.Start("gen")
+	description, err := describeDiff(rc, in, template)
+	stopGen()
+	if err == nil && strings.TrimSpace(description) == "" {
+		err = fmt.Errorf("%s returned an empty description; skipping update", providerLabels[cfg.Provider])
+	}
+	if err != nil {
+		if !cfg.DeterministicFallback {
+			return "", err
+		}
+		log.Printf("Warning: %v; writing a deterministic description instead", err)
+		return buildDeterministicDescription(template, fullDiff), nil
+	}
+	return description, nil
+}
+
+// describeDiff returns the model's description for the prompt, from the cache when the same
+// template, prompt and model settings were used before.
+func describeDiff(rc *runContext, in PromptInput, template string) (string, error) {
+	cache, err := newCache(rc.cfg)
+	if err != nil {
+		return "", err
+	}
+	creq := descriptionRequest(rc.cfg, in)
+	key := descriptionCacheKey(template, creq)
+	rc.cache, rc.cacheKey = cache, key
+	rc.fingerprint = promptFingerprint(template, creq)
+	log.Printf("Prompt fingerprint: %s", rc.fingerprint)
+	if err := setActionOutput("prompt_fingerprint", rc.fingerprint); err != nil {
+		log.Printf("Warning: failed to set the prompt_fingerprint output: %v", err)
+	}
+	if description, cached := cache.Get(key); cached {
+		log.Println("Using cached description for an identical template, prompt and diff.")
+		return description, nil
+	}
+
+	result, err := generateDescription(creq)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate description: %w", err)
+	}
+	log.Printf("Generated with %s: %d prompt + %d completion tokens, finish reason %q, %d retries",
+		result.Model, result.Usage.PromptTokens, result.Usage.CompletionTokens, result.FinishReason, result.Retries)
+	if result.Truncated {
+		log.Println("Warning: the generated description hit the token limit and may be cut off")
+	}
+	rc.generation = result
+	if len(in.Sections) > 0 && strings.TrimSpace(result.Content) != "" {
+		if result.Content, err = renderStructured(result.Content, template); err != nil {
+			return "", err
+		}
+	}
+	if strings.TrimSpace(result.Content) != "" {
+		cache.Set(key, result.Content)
+	}
+	return result.Content, nil
+}
+
+// filterPromptDiff drops or shortens the parts of diff that cost tokens without telling the
+// model much: binary changes, lockfiles, files matched by DIFFSCRIBE_IGNORE_FILE, linguist-
+// generated or vendored files and, with DIFFSCRIBE_MAX_FILE_DIFF_BYTES, oversized files.
+// With DIFFSCRIBE_SCRUB_PII it also masks personal data.
+func filterPromptDiff(diff string, cfg Config) string {
+	var binaries int
+	if diff, binaries = collapseBinaryFiles(diff); binaries > 0 {
+		log.Printf("Replaced %d binary file change(s) with one-line notes", binaries)
+	}
+	if cfg.CollapseLockfiles {
+		var lockfiles int
+		if diff, lockfiles = collapseLockfiles(diff); lockfiles > 0 {
+			log.Printf("Collapsed %d dependency lockfile change(s) to summary lines", lockfiles)
+		}
+	}
+	if cfg.IgnoreFile != "" {
+		var ignored []string
+		if diff, ignored = excludeIgnoredFiles(diff, readIgnoreFile(cfg.IgnoreFile)); len(ignored) > 0 {
+			log.Printf("Left out %d file(s) matched by %s", len(ignored), cfg.IgnoreFile)
+		}
+	}
+	if cfg.SkipLinguist {
+		var stripped []string
+		if diff, stripped = stripLinguistFiles(diff, readLinguistRules()); len(stripped) > 0 {
+			log.Printf("Left out %d generated or vendored file(s) marked in .gitattributes", len(stripped))
+		}
+	}
+	if cfg.MaxFileDiffBytes > 0 {
+		diff = capLargeFiles(diff, cfg.MaxFileDiffBytes)
+	}
+	if cfg.ScrubPII {
+		var counts piiCounts
+		if diff, counts = scrubPII(diff, cfg.PIIPatterns); counts.Total() > 0 {
+			log.Printf("Scrubbed personal data from the diff: %s", counts)
+		}
+	}
+	return diff
+}
+
+// fetchDiff returns the diff to describe: the compare diff between DIFFSCRIBE_BASE_REF
+// (defaulting to the repository's default branch) and DIFFSCRIBE_HEAD_REF (defaulting to the
+// PR head) when either is configured, otherwise the
+// full PR diff. Any problem with the range falls back to the full PR diff.
+func fetchDiff(rc *runContext) (string, error) {
+	cfg := rc.cfg
+	if cfg.BaseRef != "" || cfg.HeadRef != "" {
+		diff, err := fetchRangeDiff(rc)
+		if err == nil {
+			return diff, nil
+		}
+		log.Printf("Warning: %v; falling back to the full PR diff", err)
+	}
+	if cfg.MergeDiff != "pr" {
+		diff, err := fetchMergeAwareDiff(rc)
+		if err == nil && diff != "" {
+			return diff, nil
+		}
+		if err != nil {
+			log.Printf("Warning: %v; falling back to the full PR diff", err)
+		}
+	}
+	if cfg.DiffSource == "files" {
+		return fetchPrFilesDiff(cfg)
+	}
+	diff, err := fetchPrDiff(cfg.Repository, cfg.PRNumber, cfg.GitHubToken)
+	if err != nil && cfg.DiffSource == "auto" {
+		log.Printf("Warning: %v; assembling the diff from the PR files API instead", err)
+		return fetchPrFilesDiff(cfg)
+	}
+	return diff, err
+}
+
+// fetchPrFilesDiff builds the PR diff from the paginated files API.
+func fetchPrFilesDiff(cfg Config) (string, error) {
+	files, err := fetchPrFiles(cfg.Repository, cfg.PRNumber, cfg.GitHubToken)
 	if err != nil {
-		log.Fatalf("Failed to generate description: %v", err)
+		return "", fmt.Errorf("failed to list PR files: %w", err)
 	}
-	if strings.TrimSpace(filledDescription) == "" {
-		log.Fatal("GitHub Models returned an empty description; skipping update")
+	log.Printf("Fetched %d changed file(s) from the PR files API", len(files))
+	return filesToDiff(files), nil
+}
+
+// fetchMergeAwareDiff fetches the diff between the PR's base and head commits as selected by
+// DIFFSCRIBE_MERGE_DIFF. In "auto" mode the compare diff is only used when the branch
+// contains merge commits, whose base-branch changes can leak into the PR diff; otherwise it
+// returns "" so the regular PR diff is used.
+func fetchMergeAwareDiff(rc *runContext) (string, error) {
+	cfg := rc.cfg
+	sep := "..."
+	switch cfg.MergeDiff {
+	case "two-dot":
+		sep = ".."
+	case "auto":
+		commits, err := rc.prCommits()
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch PR commits: %w", err)
+		}
+		merges := countMerges(commits)
+		if merges == 0 {
+			return "", nil
+		}
+		log.Printf("PR branch contains %d merge commit(s); using a three-dot compare diff to exclude base-branch changes", merges)
+	}
+
+	pr, err := rc.pullRequest()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve PR base and head: %w", err)
 	}
-	log.Printf("Description generated: %d chars", len(filledDescription))
+	log.Printf("Fetching diff for range %s%s%s...", pr.Base.SHA, sep, pr.Head.SHA)
+	return fetchCompareDiff(cfg.Repository, pr.Base.SHA, sep, pr.Head.SHA, cfg.GitHubToken)
+}
+
+// fetchRangeDiff validates the configured refs and fetches the diff between them.
+func fetchRangeDiff(rc *runContext) (string, error) {
+	cfg := rc.cfg
+	base, head := cfg.BaseRef, cfg.HeadRef
+	if base == "" {
+		branch, err := fetchDefaultBranch(cfg.Repository, cfg.GitHubToken)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve default branch: %w", err)
+		}
+		base = branch
+	}
+	if head == "" {
+		pr, err := rc.pullRequest()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve PR head: %w", err)
+		}
+		head = pr.Head.SHA
+	}
+
+	for _, ref := range []string{base, head} {
+		if _, err := resolveRef(cfg.Repository, ref, cfg.GitHubToken); err != nil {
+			return "", err
+		}
+	}
+	log.Printf("Fetching diff for range %s...%s", base, head)
+	return fetchCompareDiff(cfg.Repository, base, "...", head, cfg.GitHubToken)
+}
 
-	log.Println("Updating PR body...")
-	if err := updatePrBody(repository, prNumber, filledDescription, token); err != nil {
-		log.Fatalf("Failed to update PR body: %v", err)
+// suggestSquashMessage generates and posts a squash-merge commit message for the PR.
+func suggestSquashMessage(cfg Config, diff string) error {
+	message, err := generateSquashMessage(diff)
+	if err != nil {
+		return err
+	}
+	message, _ = redactSecrets(message)
+	if err := postSquashMessage(cfg.Repository, cfg.PRNumber, message, cfg.GitHubToken); err != nil {
+		return err
 	}
-	log.Println("PR description updated successfully.")
+	log.Println("Squash commit message suggestion posted on PR.")
+	return nil
+}
 
-	if err := postComment(repository, prNumber, token); err != nil {
-		log.Fatalf("Failed to post comment: %v", err)
+// logMapping logs which diff files the model says informed each section.
+func logMapping(mapping map[string][]string) {
+	if mapping == nil {
+		log.Println("Debug: the model did not return a diff-to-section mapping")
+		return
+	}
+	for section, files := range mapping {
+		log.Printf("Debug: section %q <- %s", section, strings.Join(files, ", "))
 	}
-	log.Println("Comment posted on PR. DiffScribe completed successfully.")
 }
 
 // isTemplateUnfilled returns true if the PR body is considered unfilled
@@ -92,7 +496,9 @@ func isTemplateUnfilled(body, template string) bool {
 		return true
 	}
 
-	return strings.Count(body, "<!--") > unfilledCommentThreshold
+	// DiffScribe's own machine-readable markers are not placeholders.
+	placeholders := strings.Count(body, "<!--") - strings.Count(body, "<!-- diffscribe:")
+	return placeholders > unfilledCommentThreshold
 }
 
 // fetchPrDiff fetches the raw unified diff for a PR from the GitHub API.
@@ -106,7 +512,7 @@ func fetchPrDiff(repo, prNum, token string) (string, error) {
 	req.Header.Set("Accept", "application/vnd.github.v3.diff")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := sendRequest(req)
 	if err != nil {
 		return "", err
 	}
@@ -123,85 +529,104 @@ func fetchPrDiff(repo, prNum, token string) (string, error) {
 	return string(data), nil
 }
 
-// generateDescription calls the GitHub Models API to produce a filled PR description.
-func generateDescription(template, currentBody, diff, token string) (string, error) {
-	prompt := fmt.Sprintf(`You are helping fill out a Pull Request description template based on the code diff provided.
-
-## PR Template
-%s
-
-## Current PR Description (may be empty or still showing template placeholders)
-%s
-
-## Code Diff
-%s
-
-## Instructions
-1. Fill in ONLY the sections that can be reasonably inferred from the diff above.
-2. For any section you cannot determine from the diff, preserve the original placeholder comment (e.g., <!-- describe your changes here -->).
-3. Return ONLY the filled template content. Do not add any extra commentary outside the template.
-4. Preserve the template's exact markdown structure, headings, and checklist format.`, template, currentBody, diff)
-
-	reqBody := map[string]any{
-		"model": "gpt-4o-mini",
-		"messages": []map[string]string{
-			{
-				"role":    "system",
-				"content": "You are an expert software engineer who writes clear, concise, and helpful Pull Request descriptions.",
-			},
-			{
-				"role":    "user",
-				"content": prompt,
-			},
-		},
-		"max_tokens":  2000,
-		"temperature": 0.3,
-	}
+// maxContinuations bounds the follow-up calls made when a description hits the token limit.
+const maxContinuations = 2
+
+// generateDescription calls the model with the description request creq. Output cut off by
+// the token limit is extended with up to maxContinuations follow-up calls.
+func generateDescription(creq completionRequest) (GenerationResult, error) {
+	result, err := complete(creq)
+	for n := 1; err == nil && result.Truncated && n <= maxContinuations; n++ {
+		log.Printf("Description hit the token limit; requesting continuation %d/%d...", n, maxContinuations)
+		var next GenerationResult
+		if next, err = continueGeneration(result.Content, creq); err != nil {
+			return result, fmt.Errorf("failed to continue truncated description: %w", err)
+		}
+		result.Content = stitchContinuation(result.Content, next.Content)
+		result.FinishReason, result.Truncated = next.FinishReason, next.Truncated
+		result.Usage.PromptTokens += next.Usage.PromptTokens
+		result.Usage.CompletionTokens += next.Usage.CompletionTokens
+		result.Usage.TotalTokens += next.Usage.TotalTokens
+		result.Retries += next.Retries
+	}
+	return result, err
+}
 
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
+// defaultMaxTokens caps a description generated by model; reasoning models spend part of the
+// cap on hidden reasoning, so they get more room.
+func defaultMaxTokens(model string) int {
+	if isReasoningModel(model) {
+		return 8000
 	}
+	return 2000
+}
 
-	req, err := http.NewRequest(http.MethodPost, githubModelsBase+"/chat/completions", bytes.NewReader(bodyBytes))
-	if err != nil {
-		return "", err
+// descriptionRequest builds the chat completion request for the description prompt, with the
+// sampling parameters and extra fields of cfg.
+func descriptionRequest(cfg Config, in PromptInput) completionRequest {
+	creq := completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: descriptionSystemPrompt},
+			{Role: "user", Content: buildPrompt(in)},
+		},
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+		TopP:        cfg.TopP,
+		Extra:       cfg.ExtraParams,
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
+	if len(in.Sections) > 0 {
+		creq.JSON = true
+		creq.Schema = sectionSchema(in.Sections)
 	}
-	defer resp.Body.Close()
+	return creq
+}
 
-	respBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+// promptTokenCount estimates the tokens of the description request for in, system prompt and
+// structured output schema included, without its diff; withSummaries counts the instruction
+// added when the diff is replaced by commit summaries.
+func promptTokenCount(cfg Config, in PromptInput, withSummaries bool) int {
+	if withSummaries {
+		in.Instructions = append(in.Instructions[:len(in.Instructions):len(in.Instructions)], commitSummaryInstruction)
+	}
+	in.Diff = ""
+	creq := descriptionRequest(cfg, in)
+	tokens := 0
+	for _, m := range creq.Messages {
+		tokens += approxTokens(m.Content)
+	}
+	if creq.Schema != nil {
+		if schema, err := json.Marshal(creq.Schema); err == nil {
+			tokens += approxTokens(string(schema))
+		}
+	}
+	return tokens
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub Models API returned status %d: %s", resp.StatusCode, string(respBytes))
-	}
+// continueGeneration asks the model to carry on from partial, the truncated output of creq.
+func continueGeneration(partial string, creq completionRequest) (GenerationResult, error) {
+	creq.Messages = append(creq.Messages[:len(creq.Messages):len(creq.Messages)],
+		chatMessage{Role: "assistant", Content: partial},
+		chatMessage{Role: "user", Content: "Your previous answer was cut off. Continue exactly where it stopped, without repeating anything already written and without any commentary."},
+	)
+	return complete(creq)
+}
 
-	var result struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-	}
-	if err := json.Unmarshal(respBytes, &result); err != nil {
-		return "", err
+// stitchContinuation appends next to partial, dropping any text (of at least 16 bytes, so
+// chance matches are ignored) the model repeated from the end of partial.
+func stitchContinuation(partial, next string) string {
+	for n := min(len(partial), len(next), 200); n >= 16; n-- {
+		if strings.HasSuffix(partial, next[:n]) {
+			return partial + next[n:]
+		}
 	}
-	if len(result.Choices) == 0 {
-		return "", fmt.Errorf("no choices returned from GitHub Models API")
-	}
-	return result.Choices[0].Message.Content, nil
+	return partial + next
 }
 
-// updatePrBody patches the PR body via the GitHub REST API.
+// errBodyEditDisabled is returned by publishBody in safe mode.
+var errBodyEditDisabled = errors.New("PR body editing is disabled by policy (DIFFSCRIBE_SAFE_MODE)")
+
+// updatePrBody patches the PR body via the GitHub REST API. Only publishBody calls it, after
+// checking DIFFSCRIBE_SAFE_MODE.
 func updatePrBody(repo, prNum, body, token string) error {
 	reqBody := map[string]string{"body": body}
 	bodyBytes, err := json.Marshal(reqBody)
@@ -218,7 +643,7 @@ func updatePrBody(repo, prNum, body, token string) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := sendRequest(req)
 	if err != nil {
 		return err
 	}
@@ -232,8 +657,10 @@ func updatePrBody(repo, prNum, body, token string) error {
 }
 
 // postUnfilledNotice posts a comment as soon as an unfilled template is detected,
-// informing the author that DiffScribe will fill the description automatically.
-func postUnfilledNotice(repo, prNum, token string) error {
+// informing the author that DiffScribe will fill the description automatically. A non-empty
+// onboarding note is added above the footer, and a hidden marker records the run time for
+// DIFFSCRIBE_COOLDOWN.
+func postUnfilledNotice(repo, prNum, token, onboarding string) error {
 	commentBody := `### ⚠️ PR Template Not Filled Out
 
 This PR description template has **not been filled out**.
@@ -241,15 +668,39 @@ This PR description template has **not been filled out**.
 **DiffScribe** has detected that the description still contains unfilled placeholders. It will now automatically analyse the code diff and fill in the PR description.
 
 > ⏳ Please wait — DiffScribe is processing the diff and will update the PR description shortly.
-
----
-*Powered by [DiffScribe](https://github.com/DiffScribe) using GitHub Models (gpt-4o-mini)*`
+`
+	if onboarding != "" {
+		commentBody += "\n" + onboarding
+	}
+	commentBody += "\n" + commentFooter + "\n" + runMarker(time.Now())
 
 	return postIssueComment(repo, prNum, token, commentBody)
 }
 
+// postWIPReminder posts a short note on a work-in-progress PR that DiffScribe will fill the
+// description once the title no longer marks it as WIP.
+func postWIPReminder(repo, prNum, token string) error {
+	commentBody := `### 🚧 DiffScribe — Work in Progress
+
+This PR's title marks it as a work in progress, so **DiffScribe** has not filled the description yet. Remember to describe the change before requesting review.
+`
+	return postIssueComment(repo, prNum, token, commentBody+"\n"+commentFooter)
+}
+
+// postTooLargeComment asks the author to describe a PR that is too large for DiffScribe to
+// summarise reliably; reason says which limit it exceeds.
+func postTooLargeComment(repo, prNum, token, reason string) error {
+	commentBody := fmt.Sprintf(`### 📏 DiffScribe — PR Too Large to Summarise
+
+**DiffScribe** did not fill the description because %s, too large to auto-summarise reliably. Please describe the change manually, or consider splitting it into smaller PRs.
+`, reason)
+	return postIssueComment(repo, prNum, token, commentBody+"\n"+commentFooter)
+}
+
 // postComment posts a comment on the PR informing the author that DiffScribe filled the description.
-func postComment(repo, prNum, token string) error {
+// When no section could be filled it says so instead of claiming success. Each note (quality
+// score, ...) is added as its own paragraph above the footer.
+func postComment(repo, prNum, token string, filledSections int, notes []string) error {
 	commentBody := `### ✅ DiffScribe — PR Description Auto-filled
 
 **DiffScribe** has automatically filled the PR description based on the code diff.
@@ -258,9 +709,27 @@ Please review each section and:
 - Correct anything that was inferred incorrectly
 - Fill in sections that could not be determined from the diff (marked with placeholder comments)
 - Add any additional context that would help reviewers
+`
+	if filledSections == 0 {
+		commentBody = `### ℹ️ DiffScribe — Not Enough Information in the Diff
+
+**DiffScribe** analysed the code diff but could not infer enough to fill any section of the PR description, so the placeholders were left as they are.
+
+Please fill in the description manually so reviewers have the context they need.
+`
+	}
+	for _, note := range notes {
+		commentBody += "\n" + note + "\n"
+	}
+	commentBody += "\n" + commentFooter
+
+	return postIssueComment(repo, prNum, token, commentBody)
+}
 
----
-*Powered by [DiffScribe](https://github.com/DiffScribe) using GitHub Models (gpt-4o-mini)*`
+// postDescriptionComment posts the generated description as a comment, for runs that do not
+// edit the PR body themselves.
+func postDescriptionComment(repo, prNum, description, token string) error {
+	commentBody := "### 📝 DiffScribe — Suggested PR Description\n\n" + description + "\n\n" + commentFooter
 
 	return postIssueComment(repo, prNum, token, commentBody)
 }
@@ -282,7 +751,7 @@ func postIssueComment(repo, prNum, token, body string) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := sendRequest(req)
 	if err != nil {
 		return err
 	}
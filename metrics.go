@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timings records how long each phase of the process takes; phases repeated in batch mode
+// accumulate.
+var timings = newPhaseTimer()
+
+// phaseTimer accumulates wall-clock time per named phase, keeping first-seen order.
+type phaseTimer struct {
+	mu        sync.Mutex
+	start     time.Time
+	order     []string
+	durations map[string]time.Duration
+}
+
+// newPhaseTimer returns a timer whose total runs from now.
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{start: time.Now(), durations: make(map[string]time.Duration)}
+}
+
+// Start begins timing phase and returns the function that ends it, e.g.
+// defer timings.Start("diff")().
+func (t *phaseTimer) Start(phase string) func() {
+	begin := time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.durations[phase]; !ok {
+			t.order = append(t.order, phase)
+		}
+		t.durations[phase] += time.Since(begin)
+	}
+}
+
+// String formats the phases as "timings: diff=1.2s gen=4.5s total=6.3s".
+func (t *phaseTimer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	b.WriteString("timings:")
+	for _, phase := range t.order {
+		fmt.Fprintf(&b, " %s=%.1fs", phase, t.durations[phase].Seconds())
+	}
+	fmt.Fprintf(&b, " total=%.1fs", time.Since(t.start).Seconds())
+	return b.String()
+}
+
+// Millis returns each phase's duration, and the total, in milliseconds.
+func (t *phaseTimer) Millis() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ms := map[string]int64{"total": time.Since(t.start).Milliseconds()}
+	for phase, d := range t.durations {
+		ms[phase] = d.Milliseconds()
+	}
+	return ms
+}
+
+// runMetrics is the machine-readable summary written to DIFFSCRIBE_METRICS_FILE.
+type runMetrics struct {
+	RateLimit rateLimitStats   `json:"rate_limit"`
+	TimingsMs map[string]int64 `json:"timings_ms"`
+	Usage     usageReport      `json:"usage"`
+
+	// CostUSD is the estimated cost of the model calls; it is left out when a model has no
+	// known price.
+	CostUSD *float64 `json:"estimated_cost_usd,omitempty"`
+}
+
+// reportMetrics logs the run metrics and, when path is set, writes them as JSON.
+func reportMetrics(path string) {
+	m := runMetrics{RateLimit: apiLimiter.Stats(), TimingsMs: timings.Millis(), Usage: usage.Snapshot()}
+	if cost, ok := m.Usage.Cost(); ok {
+		m.CostUSD = &cost
+	}
+	log.Println(timings)
+	if apiLimiter != nil {
+		log.Printf("Rate limiter: %d request(s), %d delayed, waited %dms total (max %dms)",
+			m.RateLimit.Requests, m.RateLimit.Delayed, m.RateLimit.TotalWaitMs, m.RateLimit.MaxWaitMs)
+	}
+	if path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Printf("Warning: failed to encode metrics: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		log.Printf("Warning: failed to write metrics file: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// maxMilestoneBytes bounds how much of the milestone description is added to the prompt.
+const maxMilestoneBytes = 1000
+
+// milestoneContext describes the PR's milestone (its description without HTML comments, cut
+// to maxMilestoneBytes) for the prompt, or returns "" when it has none.
+func milestoneContext(rc *runContext) string {
+	pr, err := rc.pullRequest()
+	if err != nil {
+		log.Printf("Warning: failed to fetch PR milestone: %v", err)
+		return ""
+	}
+	if pr.Milestone == nil || strings.TrimSpace(pr.Milestone.Title) == "" {
+		return ""
+	}
+	log.Printf("Including milestone %q as prompt context", pr.Milestone.Title)
+	return formatMilestone(pr.Milestone.Title, pr.Milestone.Description)
+}
+
+// formatMilestone renders a milestone's title and description for the prompt.
+func formatMilestone(title, description string) string {
+	note := "This PR belongs to the milestone \"" + title + "\"."
+	desc := strings.TrimSpace(htmlCommentPattern.ReplaceAllString(description, ""))
+	if len(desc) > maxMilestoneBytes {
+		desc = strings.ToValidUTF8(desc[:maxMilestoneBytes], "") + "\n..."
+	}
+	if desc != "" {
+		note += "\n\n" + desc
+	}
+	return note
+}
+
+// milestonePart adds the PR's milestone to the prompt with DIFFSCRIBE_USE_MILESTONE.
+func milestonePart(rc *runContext) promptPart {
+	if !rc.cfg.UseMilestone {
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Milestone", Text: milestoneContext(rc)},
+		Instruction:  "Where it fits, relate the change to the goals of the milestone above; do not invent a connection.",
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatMilestone(t *testing.T) {
+	got := formatMilestone("v2.0", "<!-- internal: ship by March -->\nFaster startup.")
+	if want := "This PR belongs to the milestone \"v2.0\".\n\nFaster startup."; got != want {
+		t.Errorf("formatMilestone = %q, want %q", got, want)
+	}
+	if got := formatMilestone("v2.0", "  "); got != "This PR belongs to the milestone \"v2.0\"." {
+		t.Errorf("empty description: got %q", got)
+	}
+	long := formatMilestone("v2.0", strings.Repeat("é", maxMilestoneBytes))
+	if !strings.HasSuffix(long, "\n...") || len(long) > maxMilestoneBytes+100 {
+		t.Errorf("long description was not capped: %d bytes", len(long))
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// primaryModel is the first model of modelChain, the one normally used for generation.
+var primaryModel = providerModels[defaultProvider]
+
+// defaultProvider is the inference provider used unless configured otherwise.
+const defaultProvider = "github-models"
+
+// maxAttempts is how many times a model is tried on outages (5xx and stalled streams) before
+// the chain moves on; it is configured from DIFFSCRIBE_MAX_ATTEMPTS in main. Rate limits are
+// retried by sendRequest alone.
+var maxAttempts = 3
+
+// retryBaseDelay doubles per attempt, up to maxRetryDelay, to give the jittered backoff
+// between attempts that carry no Retry-After hint.
+const (
+	retryBaseDelay = time.Second
+	maxRetryDelay  = 30 * time.Second
+)
+
+// modelLink is one model of the fallback chain, on the provider that serves it.
+type modelLink struct {
+	provider  string
+	endpoint  string // see providerEndpoint
+	model     string
+	generator Generator
+}
+
+// modelChain lists the models tried in order until one succeeds; it is configured from
+// DIFFSCRIBE_MODEL and DIFFSCRIBE_FALLBACK_MODEL in main.
+var modelChain []modelLink
+
+// messageRoles controls how chat messages are shaped for gateways with non-standard role
+// handling; it is configured from DIFFSCRIBE_MERGE_SYSTEM, DIFFSCRIBE_SYSTEM_ROLE and
+// DIFFSCRIBE_USER_ROLE in main.
+var messageRoles = roleConfig{System: "system", User: "user"}
+
+// roleConfig names the system and user roles and whether the system prompt is folded into
+// the first user message.
+type roleConfig struct {
+	System      string
+	User        string
+	MergeSystem bool
+}
+
+// shapeMessages renames roles per rc and, with MergeSystem, prepends the system messages to
+// the first user message instead of sending them separately.
+func shapeMessages(messages []chatMessage, rc roleConfig) []chatMessage {
+	var system []string
+	shaped := make([]chatMessage, 0, len(messages))
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			if rc.MergeSystem {
+				system = append(system, m.Content)
+				continue
+			}
+			m.Role = rc.System
+		case "user":
+			m.Role = rc.User
+		}
+		shaped = append(shaped, m)
+	}
+	if len(system) == 0 {
+		return shaped
+	}
+
+	prefix := strings.Join(system, "\n\n")
+	for i, m := range shaped {
+		if m.Role == rc.User {
+			shaped[i].Content = prefix + "\n\n" + m.Content
+			return shaped
+		}
+	}
+	return append([]chatMessage{{Role: rc.User, Content: prefix}}, shaped...)
+}
+
+// modelStatusError is a non-200 response from a provider's API.
+type modelStatusError struct {
+	Provider string
+	Status   int
+	Body     string
+
+	// RetryAfter is the wait the response asked for in its Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *modelStatusError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.Status, e.Body)
+}
+
+// newModelStatusError builds the error for a non-200 response with the given body.
+func newModelStatusError(provider string, resp *http.Response, body []byte) *modelStatusError {
+	err := &modelStatusError{Provider: provider, Status: resp.StatusCode, Body: string(body)}
+	err.RetryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"))
+	return err
+}
+
+// retryDelay is the wait before attempt+1 after err: the response's Retry-After (capped at
+// maxRateLimitWait) when it gave one, otherwise an exponential backoff with equal jitter.
+func retryDelay(attempt int, err error) time.Duration {
+	var statusErr *modelStatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return min(statusErr.RetryAfter, maxRateLimitWait)
+	}
+	d := min(retryBaseDelay<<(attempt-1), maxRetryDelay)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// isOutage reports whether err is a server-side (5xx) failure of a provider's API or a
+// stalled completion stream.
+func isOutage(err error) bool {
+	var statusErr *modelStatusError
+	return errors.As(err, &statusErr) && statusErr.Status >= 500 || errors.Is(err, errStreamStalled)
+}
+
+// systemPersona is appended to the system message of every generation call, e.g. an org's
+// writing standards; it is configured from DIFFSCRIBE_SYSTEM_PROMPT in main.
+var systemPersona string
+
+// withPersona returns messages with systemPersona added to the first system message, or as a
+// leading system message when there is none.
+func withPersona(messages []chatMessage) []chatMessage {
+	if systemPersona == "" {
+		return messages
+	}
+	out := append([]chatMessage(nil), messages...)
+	for i, m := range out {
+		if m.Role == "system" {
+			out[i].Content = m.Content + "\n\n" + systemPersona
+			return out
+		}
+	}
+	return append([]chatMessage{{Role: "system", Content: systemPersona}}, out...)
+}
+
+// descriptionSystemPrompt is the system message for description and explanation calls;
+// loadPromptFiles may replace it.
+var descriptionSystemPrompt = "You are an expert software engineer who writes clear, concise, and helpful Pull Request descriptions."
+
+// chatMessage is one message of a chat completion request.
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// completionRequest describes a single chat completion call.
+type completionRequest struct {
+	Messages    []chatMessage
+	MaxTokens   int
+	Temperature float64
+	TopP        float64 // 0 leaves the provider's default
+	// JSON asks the model for a JSON object response.
+	JSON bool
+	// Schema is the JSON schema the response must match, for providers that support one.
+	Schema map[string]any
+	// Extra holds additional request body fields; they never replace model or messages.
+	Extra map[string]any
+}
+
+// GenerationResult is the outcome of a chat completion call.
+type GenerationResult struct {
+	Content      string
+	Model        string // the model that produced Content (a later one after a failover)
+	FinishReason string // e.g. "stop", or "length" when MaxTokens cut the output short
+	Usage        tokenUsage
+	Truncated    bool // the output was cut off by the token limit
+	Retries      int  // calls repeated after 5xx responses or failovers, across models
+}
+
+// tokenUsage is the token accounting reported by the models API.
+type tokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// chatCompletion sends a chat completion request and returns the content of the first choice.
+func chatCompletion(creq completionRequest) (string, error) {
+	result, err := complete(creq)
+	return result.Content, err
+}
+
+// complete sends a chat completion request down modelChain and returns the first choice with
+// its metadata. Outages are retried, and when a model still errors (including a 429 that
+// outlasted sendRequest's rate-limit retries) or returns empty output the request fails over
+// to the next model in the chain.
+func complete(creq completionRequest) (GenerationResult, error) {
+	if len(modelChain) == 0 {
+		return GenerationResult{}, fmt.Errorf("no model configured")
+	}
+	creq.Messages = withPersona(redactMessages(creq.Messages))
+	var result GenerationResult
+	var err error
+	retries := 0
+	for i, link := range modelChain {
+		if i > 0 {
+			log.Printf("Warning: %s failed (%v); failing over to %s", modelChain[i-1].model, err, link.model)
+			retries++
+		}
+		result, err = completeWithRetries(link.generator, link.model, creq)
+		retries += result.Retries
+		if err == nil && strings.TrimSpace(result.Content) == "" {
+			err = fmt.Errorf("%s returned an empty response", link.model)
+		}
+		if err == nil {
+			break
+		}
+	}
+	result.Retries = retries
+	return result, err
+}
+
+// completeWithRetries calls model up to maxAttempts times while it fails with an outage,
+// backing off between attempts. Other errors, 429s included, are returned at once: the
+// transport has already retried those that are worth it.
+func completeWithRetries(g Generator, model string, creq completionRequest) (GenerationResult, error) {
+	var result GenerationResult
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if result, err = g.Generate(model, creq); err == nil || !isOutage(err) {
+			if err == nil {
+				usage.Record(model, result.Usage)
+			}
+			result.Retries = attempt - 1
+			return result, err
+		}
+		if attempt < maxAttempts {
+			wait := retryDelay(attempt, err)
+			log.Printf("Warning: %s attempt %d/%d failed: %v; retrying in %s", model, attempt, maxAttempts, err, wait.Round(100*time.Millisecond))
+			time.Sleep(wait)
+		}
+	}
+	return GenerationResult{Model: model, Retries: maxAttempts - 1}, err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeGenerator returns errs in turn, then succeeds, counting its calls.
+type fakeGenerator struct {
+	errs  []error
+	calls int
+}
+
+func (g *fakeGenerator) Generate(model string, creq completionRequest) (GenerationResult, error) {
+	g.calls++
+	if g.calls <= len(g.errs) {
+		return GenerationResult{}, g.errs[g.calls-1]
+	}
+	return GenerationResult{Content: "ok", Model: model}, nil
+}
+
+func TestCompleteWithRetries(t *testing.T) {
+	defer func(n int) { maxAttempts = n }(maxAttempts)
+	maxAttempts = 3
+	outage := &modelStatusError{Provider: "GitHub Models", Status: http.StatusServiceUnavailable, RetryAfter: time.Millisecond}
+	limited := &modelStatusError{Provider: "GitHub Models", Status: http.StatusTooManyRequests, RetryAfter: time.Millisecond}
+
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int
+		retries int
+		wantErr bool
+	}{
+		{name: "succeeds first time", calls: 1},
+		{name: "5xx then success", errs: []error{outage}, calls: 2, retries: 1},
+		{name: "stalled stream then success", errs: []error{errStreamStalled}, calls: 2, retries: 1},
+		{name: "5xx on every attempt", errs: []error{outage, outage, outage}, calls: 3, retries: 2, wantErr: true},
+		{name: "429 is left to the transport", errs: []error{limited}, calls: 1, wantErr: true},
+		{name: "other errors are not retried", errs: []error{errors.New("bad request")}, calls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &fakeGenerator{errs: tt.errs}
+			result, err := completeWithRetries(g, "gpt-4o-mini", completionRequest{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if g.calls != tt.calls || result.Retries != tt.retries {
+				t.Errorf("calls = %d, retries = %d; want %d, %d", g.calls, result.Retries, tt.calls, tt.retries)
+			}
+		})
+	}
+}
+
+func TestShapeMessages(t *testing.T) {
+	messages := []chatMessage{
+		{Role: "system", Content: "Be concise."},
+		{Role: "user", Content: "Describe this diff."},
+	}
+	tests := []struct {
+		name string
+		rc   roleConfig
+		want []chatMessage
+	}{
+		{
+			name: "default roles",
+			rc:   roleConfig{System: "system", User: "user"},
+			want: messages,
+		},
+		{
+			name: "renamed roles",
+			rc:   roleConfig{System: "developer", User: "human"},
+			want: []chatMessage{{Role: "developer", Content: "Be concise."}, {Role: "human", Content: "Describe this diff."}},
+		},
+		{
+			name: "merged system prompt",
+			rc:   roleConfig{System: "system", User: "user", MergeSystem: true},
+			want: []chatMessage{{Role: "user", Content: "Be concise.\n\nDescribe this diff."}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shapeMessages(messages, tt.rc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shapeMessages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
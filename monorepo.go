@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSummarizedPackages caps the packages summarised by the model; the rest are listed with
+// their line counts only.
+const maxSummarizedPackages = 20
+
+// maxPackageDiffBytes bounds the diff sent to the model for one package summary.
+const maxPackageDiffBytes = 12000
+
+// rootPackage names the files outside every package directory.
+const rootPackage = "(root)"
+
+// packageDiff is the part of a diff inside one monorepo package.
+type packageDiff struct {
+	Name      string
+	Files     []fileDiff
+	Additions int
+	Deletions int
+}
+
+// packageSummary is one package of the per-package breakdown.
+type packageSummary struct {
+	Name      string
+	Files     int
+	Additions int
+	Deletions int
+	Summary   string // empty when the package was not summarised
+}
+
+// packageOf returns the package a path belongs to: the directory matched by the first of
+// roots (path.Match patterns such as "packages/*" or "apps/web") that is a prefix of it, or
+// its top-level directory when none is.
+func packageOf(p string, roots []string) string {
+	parts := strings.Split(p, "/")
+	for _, root := range roots {
+		pattern := strings.Split(strings.Trim(root, "/"), "/")
+		if len(pattern) >= len(parts) {
+			continue
+		}
+		matched := true
+		for i, seg := range pattern {
+			if ok, _ := path.Match(strings.ReplaceAll(seg, "**", "*"), parts[i]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return strings.Join(parts[:len(pattern)], "/")
+		}
+	}
+	if len(parts) == 1 {
+		return rootPackage
+	}
+	return parts[0]
+}
+
+// groupByPackage splits diff into its packages, sorted by name with the root package last.
+func groupByPackage(diff string, roots []string) []packageDiff {
+	index := make(map[string]int)
+	var groups []packageDiff
+	for _, f := range splitDiffFiles(diff) {
+		if f.Path == "" {
+			continue
+		}
+		name := packageOf(f.Path, roots)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, packageDiff{Name: name})
+		}
+		groups[i].Files = append(groups[i].Files, f)
+		groups[i].Additions += f.Additions
+		groups[i].Deletions += f.Deletions
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if (groups[i].Name == rootPackage) != (groups[j].Name == rootPackage) {
+			return groups[j].Name == rootPackage
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// summarizePackages asks the model for a short summary of each package's changes, largest
+// packages first, up to maxSummarizedPackages. A failed summary leaves that package with its
+// line counts only.
+func summarizePackages(groups []packageDiff, cfg Config) []packageSummary {
+	summaries := make([]packageSummary, len(groups))
+	order := make([]int, len(groups))
+	for i, g := range groups {
+		summaries[i] = packageSummary{Name: g.Name, Files: len(g.Files), Additions: g.Additions, Deletions: g.Deletions}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return groups[order[a]].Additions+groups[order[a]].Deletions > groups[order[b]].Additions+groups[order[b]].Deletions
+	})
+	for _, i := range order[:min(len(order), maxSummarizedPackages)] {
+		diff, _ := prioritizeDiff(joinDiffFiles(groups[i].Files), maxPackageDiffBytes, cfg.TruncationNotice)
+		prompt := fmt.Sprintf(`Summarise what this Pull Request changes in the %q package of a monorepo, in one to three sentences. Name the user-visible or API-level effect rather than listing files. Return ONLY the summary.
+
+%s`, groups[i].Name, diff)
+		summary, err := summaryCompletion(prompt)
+		if err != nil {
+			log.Printf("Warning: failed to summarise package %s: %v", groups[i].Name, err)
+			continue
+		}
+		summaries[i].Summary = strings.Join(strings.Fields(summary), " ")
+	}
+	return summaries
+}
+
+// formatPackageSummaries renders the breakdown as one subsection per package, e.g.
+//
+//	### `services/api` (3 file(s), +40/-12)
+//	Adds request tracing to every handler.
+func formatPackageSummaries(summaries []packageSummary) string {
+	var b strings.Builder
+	for i, s := range summaries {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		name := "`" + s.Name + "`"
+		if s.Name == rootPackage {
+			name = "Repository root"
+		}
+		fmt.Fprintf(&b, "### %s (%d file(s), +%d/-%d)\n", name, s.Files, s.Additions, s.Deletions)
+		if s.Summary != "" {
+			b.WriteString(s.Summary + "\n")
+		}
+	}
+	return b.String()
+}
+
+// packagesHeading is the heading of the appended per-package breakdown.
+const packagesHeading = "## Changes by package"
+
+// appendPackageSummaries appends the breakdown under "## Changes by package" unless the body
+// already has such a section, so reruns never duplicate it.
+func appendPackageSummaries(body, breakdown string) string {
+	if breakdown == "" {
+		return body
+	}
+	for _, s := range splitSections(body) {
+		if sectionKey(s.Title()) == sectionKey(strings.TrimLeft(packagesHeading, "# ")) {
+			return body
+		}
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + packagesHeading + "\n" + breakdown
+}
+
+// goWorkUsePattern matches the directories of a go.work file's use directives, written
+// singly ("use ./api") or in a block.
+var goWorkUsePattern = regexp.MustCompile(`(?m)^\s*(?:use\s+)?(\./[^\s()]+|\.)\s*$`)
+
+// pnpmPackagePattern matches the entries of pnpm-workspace.yaml's packages list.
+var pnpmPackagePattern = regexp.MustCompile(`^\s*-\s*['"]?([^'"\s#]+)['"]?`)
+
+// readWorkspaceRoots returns the package directories declared by the checked-out
+// repository's workspace files: package.json "workspaces", pnpm-workspace.yaml and go.work.
+// Negated and root entries are skipped; deeper roots come first so packageOf prefers them.
+func readWorkspaceRoots() []string {
+	var roots []string
+	if data, err := os.ReadFile("package.json"); err == nil {
+		var manifest struct {
+			Workspaces json.RawMessage `json:"workspaces"`
+		}
+		if json.Unmarshal(data, &manifest) == nil && len(manifest.Workspaces) > 0 {
+			var list []string
+			if json.Unmarshal(manifest.Workspaces, &list) != nil {
+				var nested struct {
+					Packages []string `json:"packages"`
+				}
+				if json.Unmarshal(manifest.Workspaces, &nested) == nil {
+					list = nested.Packages
+				}
+			}
+			roots = append(roots, list...)
+		}
+	}
+	if data, err := os.ReadFile("pnpm-workspace.yaml"); err == nil {
+		inPackages := false
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" && line[0] != ' ' && line[0] != '-' && line[0] != '#' {
+				inPackages = strings.HasPrefix(line, "packages:")
+				continue
+			}
+			if m := pnpmPackagePattern.FindStringSubmatch(line); inPackages && m != nil {
+				roots = append(roots, m[1])
+			}
+		}
+	}
+	if data, err := os.ReadFile("go.work"); err == nil {
+		for _, m := range goWorkUsePattern.FindAllStringSubmatch(string(data), -1) {
+			roots = append(roots, m[1])
+		}
+	}
+
+	var kept []string
+	for _, root := range roots {
+		root = strings.Trim(strings.TrimPrefix(root, "./"), "/")
+		if root == "" || root == "." || strings.HasPrefix(root, "!") {
+			continue
+		}
+		kept = append(kept, root)
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return strings.Count(kept[i], "/") > strings.Count(kept[j], "/") })
+	return kept
+}
+
+// monorepoPackages summarises each changed package with DIFFSCRIBE_MONOREPO, using
+// DIFFSCRIBE_MONOREPO_ROOTS or else the workspace files. It returns nil unless the diff spans
+// more than one package.
+func monorepoPackages(cfg Config, diff string) []packageSummary {
+	if !cfg.Monorepo {
+		return nil
+	}
+	roots := cfg.MonorepoRoots
+	if len(roots) == 0 {
+		roots = readWorkspaceRoots()
+	}
+	groups := groupByPackage(diff, roots)
+	if len(groups) <= 1 {
+		return nil
+	}
+	log.Printf("Summarising %d changed package(s)...", len(groups))
+	defer timings.Start("packages")()
+	return summarizePackages(groups, cfg)
+}
+
+// packagesPart adds the per-package summaries to the prompt.
+func packagesPart(packages []packageSummary) promptPart {
+	if len(packages) == 0 {
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Changes by Package", Text: formatPackageSummaries(packages)},
+		Instruction:  "This PR spans several packages of a monorepo: organise the description around the per-package summaries above and name each affected package.",
+	}
+}
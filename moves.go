@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// moveSimilarity is the share of lines a deleted and an added file must have in common to be
+// treated as one move when git did not detect the rename.
+const moveSimilarity = 0.8
+
+// fileMove is a renamed or moved file; Additions and Deletions are the edits made with it.
+type fileMove struct {
+	From, To  string
+	Additions int
+	Deletions int
+}
+
+// detectMoves finds the renames in diff, including deleted/added file pairs with the same
+// name and nearly the same content, and returns them with the diff minus the blocks that
+// carry no edit of their own: pure renames and the detected pairs, which would otherwise
+// repeat the file's content. Renames with edits keep their hunks.
+func detectMoves(diff string) ([]fileMove, string) {
+	files := splitDiffFiles(diff)
+	drop := make([]bool, len(files))
+	var moves []fileMove
+	var deleted, added []int
+	for i, f := range files {
+		if f.Path == "" {
+			continue
+		}
+		header := f.Text
+		if h := strings.Index(header, "\n@@"); h >= 0 {
+			header = header[:h]
+		}
+		switch {
+		case f.OldPath != "" && f.OldPath != f.Path:
+			moves = append(moves, fileMove{From: f.OldPath, To: f.Path, Additions: f.Additions, Deletions: f.Deletions})
+			drop[i] = f.Additions+f.Deletions == 0
+		case strings.Contains(header, "\ndeleted file mode"):
+			deleted = append(deleted, i)
+		case strings.Contains(header, "\nnew file mode"):
+			added = append(added, i)
+		}
+	}
+
+	for _, d := range deleted {
+		for _, a := range added {
+			if drop[a] || path.Base(files[d].Path) != path.Base(files[a].Path) {
+				continue
+			}
+			removed, inserted := changedLines(files[d].Text, '-'), changedLines(files[a].Text, '+')
+			common := commonLines(removed, inserted)
+			if len(removed) == 0 || float64(common) < moveSimilarity*float64(max(len(removed), len(inserted))) {
+				continue
+			}
+			moves = append(moves, fileMove{From: files[d].Path, To: files[a].Path, Additions: len(inserted) - common, Deletions: len(removed) - common})
+			drop[d], drop[a] = true, true
+			break
+		}
+	}
+
+	var kept []fileDiff
+	for i, f := range files {
+		if !drop[i] {
+			kept = append(kept, f)
+		}
+	}
+	return moves, joinDiffFiles(kept)
+}
+
+// changedLines returns the trimmed content of a file block's hunk lines starting with marker.
+func changedLines(text string, marker byte) []string {
+	var lines []string
+	_, hunks := splitHunks(text)
+	for _, h := range hunks {
+		for _, line := range strings.Split(h, "\n")[1:] {
+			if len(line) > 0 && line[0] == marker {
+				lines = append(lines, strings.TrimSpace(line[1:]))
+			}
+		}
+	}
+	return lines
+}
+
+// commonLines counts the lines of a also in b, as a multiset.
+func commonLines(a, b []string) int {
+	counts := make(map[string]int, len(b))
+	for _, line := range b {
+		counts[line]++
+	}
+	n := 0
+	for _, line := range a {
+		if counts[line] > 0 {
+			counts[line]--
+			n++
+		}
+	}
+	return n
+}
+
+// formatMoves lists the moves one per line, folding files moved between the same two
+// directories under their own names into one line such as
+// "- pkg/util/ → pkg/stringutil/ (14 files)".
+func formatMoves(moves []fileMove) string {
+	type dirPair struct{ from, to string }
+	groups := make(map[dirPair][]fileMove)
+	var order []dirPair
+	for _, m := range moves {
+		key := dirPair{path.Dir(m.From), path.Dir(m.To)}
+		if path.Base(m.From) != path.Base(m.To) || key.from == key.to {
+			key = dirPair{m.From, m.To}
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], m)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return len(groups[order[i]]) > len(groups[order[j]]) })
+
+	var b strings.Builder
+	for _, key := range order {
+		group := groups[key]
+		additions, deletions := 0, 0
+		for _, m := range group {
+			additions += m.Additions
+			deletions += m.Deletions
+		}
+		from, to := group[0].From, group[0].To
+		var details []string
+		if len(group) > 1 {
+			from, to = key.from+"/", key.to+"/"
+			details = append(details, fmt.Sprintf("%d files", len(group)))
+		}
+		if additions+deletions > 0 {
+			details = append(details, fmt.Sprintf("with edits +%d/-%d", additions, deletions))
+		} else if len(group) == 1 {
+			details = append(details, "unchanged")
+		}
+		fmt.Fprintf(&b, "- %s → %s (%s)\n", from, to, strings.Join(details, ", "))
+	}
+	return b.String()
+}
+
+// movesPart lists the PR's renames and moves for the prompt with DIFFSCRIBE_SUMMARIZE_MOVES,
+// and returns diff with the unchanged moves left out.
+func movesPart(cfg Config, diff string) (promptPart, string) {
+	if !cfg.SummarizeMoves {
+		return promptPart{}, diff
+	}
+	moves, diff := detectMoves(diff)
+	if len(moves) == 0 {
+		return promptPart{}, diff
+	}
+	log.Printf("Detected %d renamed or moved file(s)", len(moves))
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Renames and Moves", Text: formatMoves(moves)},
+		Instruction:  "Call out the renames and moves listed above as such in the description, rather than describing the moved code as new; unchanged moves are left out of the diff.",
+	}, diff
+}
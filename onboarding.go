@@ -0,0 +1,28 @@
+package main
+
+import "log"
+
+// onboardingBlurb introduces DiffScribe the first time it runs in a repository.
+const onboardingBlurb = `#### 👋 First time here?
+
+This is the first PR **DiffScribe** has processed in this repository. Whenever a PR is opened with an unfilled description template, DiffScribe reads the code diff and fills in the sections it can infer, leaving the rest (e.g. manual testing steps) with their placeholders. You stay in control: edit anything it gets wrong, and a filled-in description is never overwritten.
+`
+
+// firstRunInRepo reports whether DiffScribe has not yet run in the repository, which is
+// recorded by the presence of the onboarding label. Lookup failures count as "not first" so
+// the blurb is never posted repeatedly.
+func firstRunInRepo(cfg Config) bool {
+	exists, err := labelExists(cfg.Repository, cfg.OnboardingLabel, cfg.GitHubToken)
+	if err != nil {
+		log.Printf("Warning: failed to check onboarding label: %v", err)
+		return false
+	}
+	return !exists
+}
+
+// markOnboarded creates the onboarding label so later runs skip the blurb.
+func markOnboarded(cfg Config) {
+	if err := createLabel(cfg.Repository, cfg.OnboardingLabel, "5319e7", "Marks that DiffScribe has run in this repository", cfg.GitHubToken); err != nil {
+		log.Printf("Warning: failed to create onboarding label %q: %v", cfg.OnboardingLabel, err)
+	}
+}
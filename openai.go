@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// openAIBase is the OpenAI API used by DIFFSCRIBE_PROVIDER=openai.
+const openAIBase = "https://api.openai.com/v1"
+
+// openAIChat is the Generator for OpenAI-style chat completions APIs: GitHub Models, the
+// default provider, OpenAI itself, Azure OpenAI and local Ollama or llama.cpp servers.
+type openAIChat struct {
+	name    string // the provider name used in errors, e.g. "GitHub Models"
+	baseURL string
+	token   string // sent as a bearer token unless empty
+
+	// azureAPIVersion, when set, addresses Azure OpenAI: the model names a deployment under
+	// baseURL and the token is sent as an api-key header.
+	azureAPIVersion string
+}
+
+// endpoint returns the chat completions URL for model.
+func (g openAIChat) endpoint(model string) string {
+	if g.azureAPIVersion != "" {
+		return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+			strings.TrimRight(g.baseURL, "/"), neturl.PathEscape(model), neturl.QueryEscape(g.azureAPIVersion))
+	}
+	return g.baseURL + "/chat/completions"
+}
+
+// Generate performs a single chat completion call against model, streamed when
+// DIFFSCRIBE_STREAM is set.
+func (g openAIChat) Generate(model string, creq completionRequest) (GenerationResult, error) {
+	reqBody := map[string]any{
+		"model":    model,
+		"messages": shapeMessages(creq.Messages, messageRoles),
+	}
+	if isReasoningModel(model) {
+		// Reasoning models reject temperature and count their hidden reasoning against
+		// max_completion_tokens rather than max_tokens.
+		reqBody["max_completion_tokens"] = creq.MaxTokens
+	} else {
+		reqBody["max_tokens"] = creq.MaxTokens
+		reqBody["temperature"] = creq.Temperature
+		if creq.TopP > 0 {
+			reqBody["top_p"] = creq.TopP
+		}
+	}
+	if creq.Schema != nil {
+		reqBody["response_format"] = map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "response", "strict": true, "schema": creq.Schema},
+		}
+	} else if creq.JSON {
+		reqBody["response_format"] = map[string]string{"type": "json_object"}
+	}
+	for k, v := range creq.Extra {
+		if k != "model" && k != "messages" {
+			reqBody[k] = v
+		}
+	}
+	if streamResponses {
+		reqBody["stream"] = true
+		reqBody["stream_options"] = map[string]bool{"include_usage": true}
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.endpoint(model), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	if g.azureAPIVersion != "" {
+		req.Header.Set("api-key", g.token)
+	} else if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if streamResponses && resp.StatusCode == http.StatusOK {
+		return readChatStream(resp.Body, model, streamIdleTimeout)
+	}
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return GenerationResult{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return GenerationResult{}, newModelStatusError(g.name, resp, respBytes)
+	}
+
+	var result struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage tokenUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(respBytes, &result); err != nil {
+		return GenerationResult{}, err
+	}
+	if len(result.Choices) == 0 {
+		return GenerationResult{}, fmt.Errorf("no choices returned from %s API", g.name)
+	}
+	choice := result.Choices[0]
+	if result.Model == "" {
+		result.Model = model
+	}
+	return GenerationResult{
+		Content:      choice.Message.Content,
+		Model:        result.Model,
+		FinishReason: choice.FinishReason,
+		Usage:        result.Usage,
+		Truncated:    choice.FinishReason == "length",
+	}, nil
+}
+
+// isReasoningModel reports whether model is an OpenAI reasoning model (o1, o3-mini, o4-mini,
+// gpt-5, ...), optionally with a publisher prefix such as GitHub Models' "openai/".
+func isReasoningModel(model string) bool {
+	name := strings.ToLower(model[strings.LastIndex(model, "/")+1:])
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if name == prefix || strings.HasPrefix(name, prefix+"-") {
+			return true
+		}
+	}
+	return false
+}
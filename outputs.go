@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// OutputTarget names one destination for the generated description (DIFFSCRIBE_OUTPUTS).
+type OutputTarget string
+
+const (
+	OutputBody       OutputTarget = "body"
+	OutputComment    OutputTarget = "comment"
+	OutputReview     OutputTarget = "review"
+	OutputCheckRun   OutputTarget = "checkrun"
+	OutputStdoutJSON OutputTarget = "stdout-json"
+	OutputSuggest    OutputTarget = "suggest-block"
+)
+
+// defaultOutputs preserves the original behaviour: update the body, then post a comment.
+var defaultOutputs = []string{string(OutputBody), string(OutputComment)}
+
+// outputHandlers publishes the generated description to each target.
+var outputHandlers = map[OutputTarget]func(*runContext) error{
+	OutputBody:       publishBody,
+	OutputComment:    publishComment,
+	OutputReview:     publishReview,
+	OutputCheckRun:   publishCheckRun,
+	OutputStdoutJSON: publishStdoutJSON,
+	OutputSuggest:    publishSuggestBlock,
+}
+
+// runContext carries the state of one DiffScribe run into the output targets.
+type runContext struct {
+	cfg         Config
+	description string
+	truncated   bool
+	bodyUpdated bool
+
+	// changedPaths are the files touched by the full (untruncated) diff.
+	changedPaths []string
+
+	// filledSections is how many template sections the description filled.
+	filledSections int
+
+	// commentNotes are extra paragraphs for the completion comment.
+	commentNotes []string
+
+	// generation describes the model call that produced the description; it is zero when the
+	// description came from the cache.
+	generation GenerationResult
+
+	// fingerprint is the promptFingerprint of the description request; it is empty when no
+	// model prompt was built.
+	fingerprint string
+
+	// cache and cacheKey are where describeDiff looked the description up; both are unset
+	// when no model prompt was built.
+	cache    Cache
+	cacheKey string
+
+	pr      *PullRequest
+	commits []Commit
+}
+
+// pullRequest fetches the PR metadata once per run.
+func (rc *runContext) pullRequest() (*PullRequest, error) {
+	if rc.pr != nil {
+		return rc.pr, nil
+	}
+	pr, err := fetchPullRequest(rc.cfg.Repository, rc.cfg.PRNumber, rc.cfg.GitHubToken)
+	if err != nil {
+		return nil, err
+	}
+	rc.pr = pr
+	return pr, nil
+}
+
+// prCommits fetches the PR's commits once per run.
+func (rc *runContext) prCommits() ([]Commit, error) {
+	if rc.commits != nil {
+		return rc.commits, nil
+	}
+	commits, err := fetchPrCommits(rc.cfg.Repository, rc.cfg.PRNumber, rc.cfg.GitHubToken)
+	if err != nil {
+		return nil, err
+	}
+	rc.commits = commits
+	return commits, nil
+}
+
+// addNotes adds the non-empty notes to the completion comment, in order.
+func (rc *runContext) addNotes(notes ...string) {
+	for _, note := range notes {
+		if note != "" {
+			rc.commentNotes = append(rc.commentNotes, note)
+		}
+	}
+}
+
+// parseOutputTargets validates a list of target names, dropping duplicates.
+func parseOutputTargets(names []string) ([]OutputTarget, error) {
+	var targets []OutputTarget
+	for _, name := range names {
+		target := OutputTarget(name)
+		if _, ok := outputHandlers[target]; !ok {
+			return nil, fmt.Errorf("unknown output target %q in DIFFSCRIBE_OUTPUTS", name)
+		}
+		if !hasOutputTarget(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// hasOutputTarget reports whether target is in targets.
+func hasOutputTarget(targets []OutputTarget, target OutputTarget) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// runOutputs publishes to every target in order, continuing past failures, and returns one
+// error per failed target.
+func runOutputs(rc *runContext, targets []OutputTarget) []error {
+	var errs []error
+	for _, target := range targets {
+		log.Printf("Publishing to output target %q...", target)
+		stop := timings.Start(string(target))
+		err := outputHandlers[target](rc)
+		stop()
+		if err != nil {
+			log.Printf("Output target %q failed: %v", target, err)
+			errs = append(errs, fmt.Errorf("%s: %w", target, err))
+		}
+	}
+	return errs
+}
+
+// publishBody replaces the PR body with the generated description. It refuses to in safe
+// mode, so no output target can edit a body while DIFFSCRIBE_SAFE_MODE is set.
+func publishBody(rc *runContext) error {
+	if rc.cfg.SafeMode {
+		return errBodyEditDisabled
+	}
+	if err := updatePrBody(rc.cfg.Repository, rc.cfg.PRNumber, rc.description, rc.cfg.GitHubToken); err != nil {
+		return err
+	}
+	rc.bodyUpdated = true
+	log.Println("PR description updated successfully.")
+	return nil
+}
+
+// publishComment posts the completion comment, or the description itself when the body was
+// not updated in this run. With DIFFSCRIBE_ZERO_FILL_COMMENT=skip nothing is posted when no
+// section was filled.
+func publishComment(rc *runContext) error {
+	if rc.bodyUpdated {
+		if rc.filledSections == 0 && rc.cfg.ZeroFillComment == "skip" {
+			log.Println("No sections were filled; skipping the completion comment.")
+			return nil
+		}
+		return postComment(rc.cfg.Repository, rc.cfg.PRNumber, rc.cfg.GitHubToken, rc.filledSections, rc.commentNotes)
+	}
+	return postDescriptionComment(rc.cfg.Repository, rc.cfg.PRNumber, rc.description, rc.cfg.GitHubToken)
+}
+
+// publishSuggestBlock posts the description as raw markdown in a collapsible code block, so
+// authors can copy it into the PR body verbatim.
+func publishSuggestBlock(rc *runContext) error {
+	fence := codeFenceFor(rc.description)
+	commentBody := fmt.Sprintf("### 📝 DiffScribe — Suggested PR Description\n\n<details><summary>Proposed description (click to copy)</summary>\n\n%s\n%s\n%s\n\n</details>\n\n%s",
+		fence, strings.TrimRight(rc.description, "\n"), fence, commentFooter)
+	return postIssueComment(rc.cfg.Repository, rc.cfg.PRNumber, rc.cfg.GitHubToken, commentBody)
+}
+
+// publishReview submits the description as a non-blocking PR review.
+func publishReview(rc *runContext) error {
+	payload := map[string]string{
+		"body":  "### 📝 DiffScribe — Suggested PR Description\n\n" + rc.description,
+		"event": "COMMENT",
+	}
+	url := fmt.Sprintf("%s/repos/%s/pulls/%s/reviews", githubAPIBase, rc.cfg.Repository, rc.cfg.PRNumber)
+	req, err := newGitHubRequest(http.MethodPost, url, rc.cfg.GitHubToken, payload)
+	if err != nil {
+		return err
+	}
+	return doGitHubJSON(req, http.StatusOK, nil)
+}
+
+// publishCheckRun records a successful DiffScribe check run.
+func publishCheckRun(rc *runContext) error {
+	summary := fmt.Sprintf("DiffScribe generated a PR description from the code diff (%d chars).", len(rc.description))
+	if rc.bodyUpdated {
+		summary = fmt.Sprintf("DiffScribe filled the PR description from the code diff (%d chars). Review the updated description for accuracy.", len(rc.description))
+	}
+	return reportCheckRun(rc, "success", "PR description auto-filled", summary)
+}
+
+// reportCheckRun creates a DiffScribe check run on the PR head commit linking back to the PR.
+func reportCheckRun(rc *runContext, conclusion, title, summary string) error {
+	pr, err := rc.pullRequest()
+	if err != nil {
+		return err
+	}
+	summary += "\n\n[View the PR description](" + pr.HTMLURL + ")"
+	if err := createCheckRun(rc.cfg.Repository, pr, conclusion, title, summary, rc.cfg.GitHubToken); err != nil {
+		return err
+	}
+	log.Printf("Check run created with conclusion %q.", conclusion)
+	return nil
+}
+
+// publishStdoutJSON writes the run result as a single JSON object to stdout (logs go to stderr).
+func publishStdoutJSON(rc *runContext) error {
+	return json.NewEncoder(os.Stdout).Encode(struct {
+		Repository  string           `json:"repository"`
+		PRNumber    string           `json:"pr_number"`
+		Description string           `json:"description"`
+		Truncated   bool             `json:"truncated"`
+		BodyUpdated bool             `json:"body_updated"`
+		Model       string           `json:"model,omitempty"`
+		Usage       tokenUsage       `json:"usage"`
+		Fingerprint string           `json:"prompt_fingerprint,omitempty"`
+		TimingsMs   map[string]int64 `json:"timings_ms"`
+	}{rc.cfg.Repository, rc.cfg.PRNumber, rc.description, rc.truncated, rc.bodyUpdated, rc.generation.Model, rc.generation.Usage, rc.fingerprint, timings.Millis()})
+}
+
+// setActionOutput appends name=value to the step's GITHUB_OUTPUT file; outside Actions it
+// does nothing.
+func setActionOutput(name, value string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s=%s\n", name, value); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
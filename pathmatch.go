@@ -0,0 +1,107 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// compilePathPattern converts a gitignore-style pattern (as used by CODEOWNERS and ignore
+// files) into a regexp over slash-separated repository paths:
+//   - a leading "/" or an inner "/" anchors the pattern at the repository root, otherwise it
+//     matches at any depth;
+//   - "*" and "?" never match "/", while "**" matches across directories;
+//   - "[abc]", "[a-z]" and the negated "[!abc]" match one character of (or outside) the
+//     class, never "/"; a "[" without a closing "]", or with an invalid class, is literal;
+//   - a pattern also matches everything beneath a directory it names, and a trailing "/"
+//     restricts it to directories.
+func compilePathPattern(pattern string) *regexp.Regexp {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	p := strings.TrimSuffix(pattern, "/")
+	anchored := strings.HasPrefix(p, "/") || strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case p[i] == '*':
+			b.WriteString("[^/]*")
+		case p[i] == '?':
+			b.WriteString("[^/]")
+		case p[i] == '[' && classEnd(p, i) > 0:
+			end := classEnd(p, i)
+			class := bracketClass(p[i+1 : end])
+			if _, err := regexp.Compile(class); err != nil {
+				b.WriteString(`\[`) // e.g. a reversed range: keep the "[" literal
+				continue
+			}
+			b.WriteString(class)
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	if dirOnly {
+		b.WriteString("/.*$")
+	} else {
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.MustCompile(b.String())
+}
+
+// matchPathPattern reports whether path matches the gitignore-style pattern.
+func matchPathPattern(pattern, path string) bool {
+	return compilePathPattern(pattern).MatchString(strings.TrimPrefix(path, "/"))
+}
+
+// classEnd returns the index of the "]" closing the bracket expression that starts at
+// p[open], or -1 when it is not closed. A "]" right after the "[" (or "[!") is literal.
+func classEnd(p string, open int) int {
+	i := open + 1
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		i++
+	}
+	if i < len(p) && p[i] == ']' {
+		i++
+	}
+	if end := strings.IndexByte(p[i:], ']'); end >= 0 {
+		return i + end
+	}
+	return -1
+}
+
+// bracketClass converts the inside of a gitignore bracket expression into a regexp class
+// that never matches "/".
+func bracketClass(class string) string {
+	var b strings.Builder
+	b.WriteString("[")
+	if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+		b.WriteString("^/")
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		switch c := class[i]; c {
+		case '/':
+		case '\\', '[', ']', '^':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if b.Len() == 1 {
+		return `[^\x00-\x{10FFFF}]` // only "/" was listed: match nothing
+	}
+	b.WriteString("]")
+	return b.String()
+}
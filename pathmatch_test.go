@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/tool/main.go", true},
+		{"*.go", "main.go.txt", false},
+		{"**/testdata", "testdata/a.json", true},
+		{"**/testdata", "pkg/x/testdata/a.json", true},
+		{"**/testdata", "pkg/testdata2/a.json", false},
+		{"docs/**", "docs/a/b.md", true},
+		{"docs/**", "src/docs/b.md", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"/build", "build/out.o", true},
+		{"/build", "src/build/out.o", false},
+		{"build", "src/build/out.o", true},
+		{"vendor/", "vendor/lib/x.go", true},
+		{"vendor/", "vendor", false},
+		{"vendor", "vendor", true},
+		{"src/*.js", "src/a.js", true},
+		{"src/*.js", "src/lib/a.js", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file/.txt", false},
+		{"*.[ch]", "lib/x.c", true},
+		{"*.[ch]", "lib/x.h", true},
+		{"*.[ch]", "lib/x.o", false},
+		{"log[0-9].txt", "log7.txt", true},
+		{"log[!0-9].txt", "logs.txt", true},
+		{"log[!0-9].txt", "log7.txt", false},
+		{"a[/]b", "a/b", false},
+		{"data[z-a]", "data[z-a]", true},
+		{"notes[", "notes[", true},
+	}
+	for _, tt := range tests {
+		if got := matchPathPattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPathPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	rules := parseIgnoreFile("# generated code\n*.pb.go\n!keep.pb.go\n\ndocs/\n!docs/README.md\ndocs/README.md\n")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"api/service.pb.go", true},
+		{"api/keep.pb.go", false},
+		{"api/service.go", false},
+		{"docs/guide.md", true},
+		{"docs/README.md", true}, // the last matching line wins
+		{"/api/service.pb.go", true},
+	}
+	for _, tt := range tests {
+		if got := isIgnored(rules, tt.path); got != tt.want {
+			t.Errorf("isIgnored(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
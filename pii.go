@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// emailPattern matches email addresses.
+var emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`)
+
+// phonePatterns match phone numbers written with separators: international numbers with a
+// "+" country code and North American style "(555) 123-4567" / "555-123-4567". Versions,
+// dates and IP addresses do not have these shapes.
+var phonePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\+\d{1,3}[ .-]\(?\d{1,4}\)?(?:[ .-]\d{2,4}){1,3}\b`),
+	regexp.MustCompile(`(?:\(\d{3}\)\s?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`),
+}
+
+// piiCounts is how many items of each kind scrubPII masked.
+type piiCounts struct {
+	Emails, Phones, Custom int
+}
+
+// Total is the number of masked items.
+func (c piiCounts) Total() int {
+	return c.Emails + c.Phones + c.Custom
+}
+
+// String renders the counts, e.g. "2 email(s), 1 phone number(s), 0 custom match(es)".
+func (c piiCounts) String() string {
+	return fmt.Sprintf("%d email(s), %d phone number(s), %d custom match(es)", c.Emails, c.Phones, c.Custom)
+}
+
+// scrubPII masks email addresses as [EMAIL], phone numbers as [PHONE] and matches of custom
+// as [PII] in diff, and reports how many of each it masked.
+func scrubPII(diff string, custom []*regexp.Regexp) (string, piiCounts) {
+	var counts piiCounts
+	diff, counts.Emails = replaceCounting(emailPattern, diff, "[EMAIL]")
+	for _, re := range phonePatterns {
+		var n int
+		diff, n = replaceCounting(re, diff, "[PHONE]")
+		counts.Phones += n
+	}
+	for _, re := range custom {
+		var n int
+		diff, n = replaceCounting(re, diff, "[PII]")
+		counts.Custom += n
+	}
+	return diff, counts
+}
+
+// replaceCounting replaces every match of re in text with placeholder and counts them.
+func replaceCounting(re *regexp.Regexp, text, placeholder string) (string, int) {
+	n := 0
+	text = re.ReplaceAllStringFunc(text, func(string) string {
+		n++
+		return placeholder
+	})
+	return text, n
+}
+
+// parsePIIPatterns compiles DIFFSCRIBE_PII_PATTERNS: one regular expression per line, so
+// patterns may contain commas; blank lines are skipped.
+func parsePIIPatterns(raw string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("DIFFSCRIBE_PII_PATTERNS has an invalid pattern %q: %w", line, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
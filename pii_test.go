@@ -0,0 +1,43 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestScrubPII(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+		counts           piiCounts
+	}{
+		{name: "email", text: "+// Contact jane.doe@example.com for access", want: "+// Contact [EMAIL] for access", counts: piiCounts{Emails: 1}},
+		{name: "international phone", text: "call +44 20 7946 0958 now", want: "call [PHONE] now", counts: piiCounts{Phones: 1}},
+		{name: "North American phone", text: "(555) 123-4567 or 555-123-4567", want: "[PHONE] or [PHONE]", counts: piiCounts{Phones: 2}},
+		{name: "IP address", text: "listen on 1.2.3.4:8080", want: "listen on 1.2.3.4:8080"},
+		{name: "date", text: "released 2024-01-15", want: "released 2024-01-15"},
+		{name: "version", text: "bump to v10.20.30", want: "bump to v10.20.30"},
+		{name: "custom pattern", text: "employee E123456 left", want: "employee [PII] left", counts: piiCounts{Custom: 1}},
+	}
+	custom := []*regexp.Regexp{regexp.MustCompile(`\bE\d{6}\b`)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := scrubPII(tt.text, custom)
+			if got != tt.want || counts != tt.counts {
+				t.Errorf("scrubPII(%q) = %q, %s; want %q, %s", tt.text, got, counts, tt.want, tt.counts)
+			}
+		})
+	}
+}
+
+func TestParsePIIPatterns(t *testing.T) {
+	patterns, err := parsePIIPatterns("\\bE\\d{6}\\b\n\n  [A-Z]{2},\\d{4}  \n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 2 || patterns[1].String() != `[A-Z]{2},\d{4}` {
+		t.Errorf("parsePIIPatterns = %v, want two patterns, the second kept with its comma", patterns)
+	}
+	if _, err := parsePIIPatterns("ok\n(unclosed"); err == nil {
+		t.Error("an invalid pattern was accepted")
+	}
+}
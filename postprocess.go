@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// PostContext is the input of one post-processing pass: the description produced so far and
+// the run state the passes draw on.
+type PostContext struct {
+	Config      Config
+	Description string
+	Template    string
+	PRBody      string
+
+	// Truncated reports whether the model saw a truncated diff.
+	Truncated bool
+
+	// DependencyChanges are the manifest changes found in the full diff.
+	DependencyChanges []string
+
+	// FileChanges are the files of the full diff, for the file table.
+	FileChanges FileChanges
+
+	// FileSummaries are the model's one-line summaries of the changed files, by path.
+	FileSummaries map[string]string
+
+	// Symbols are the declarations the diff adds, removes or modifies.
+	Symbols []fileSymbols
+
+	// APIDelta is the exported Go API the PR adds, removes or changes, by package.
+	APIDelta []packageDelta
+
+	// Parent is the open PR this PR is stacked on, or nil.
+	Parent *PullRequest
+
+	// Packages is the per-package breakdown of a monorepo PR.
+	Packages []packageSummary
+
+	// ReleaseNote generates the release note once per run, for the description and any
+	// comparison outputs alike; nil generates it on every pass.
+	ReleaseNote *releaseNoteMemo
+}
+
+// PostProcessor is one pass over the generated description, returning the new text.
+type PostProcessor func(PostContext) (string, error)
+
+// defaultPostProcessors is the order in which the built-in passes run (DIFFSCRIBE_POST_PROCESSORS).
+var defaultPostProcessors = []string{
+	"strip-mapping", "restore-hedged", "section-limits", "mark-truncated", "stack-note",
+	"dependency-changes", "file-table", "file-summaries", "packages", "test-changes", "symbols", "api-delta", "review-checklist", "release-note", "redact", "keep-author", "body-limit",
+}
+
+// postProcessors are the available passes by name. Passes whose feature is not configured
+// return the description unchanged.
+var postProcessors = map[string]PostProcessor{
+	"strip-mapping":      stripMappingPass,
+	"restore-hedged":     restoreHedgedPass,
+	"section-limits":     sectionLimitsPass,
+	"mark-truncated":     markTruncatedPass,
+	"stack-note":         stackNotePass,
+	"dependency-changes": dependencyChangesPass,
+	"file-table":         fileTablePass,
+	"file-summaries":     fileSummariesPass,
+	"packages":           packagesPass,
+	"test-changes":       testChangesPass,
+	"symbols":            symbolsPass,
+	"api-delta":          apiDeltaPass,
+	"review-checklist":   reviewChecklistPass,
+	"release-note":       releaseNotePass,
+	"redact":             redactPass,
+	"keep-author":        keepAuthorPass,
+	"body-limit":         bodyLimitPass,
+}
+
+// parsePostProcessors validates a list of pass names, dropping duplicates.
+func parsePostProcessors(names []string) ([]string, error) {
+	var passes []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := postProcessors[name]; !ok {
+			return nil, fmt.Errorf("unknown post-processor %q in DIFFSCRIBE_POST_PROCESSORS", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			passes = append(passes, name)
+		}
+	}
+	return passes, nil
+}
+
+// runPostProcessors applies the named passes in order. A failing pass is logged and skipped,
+// leaving the description as the previous pass returned it.
+func runPostProcessors(pc PostContext, names []string) string {
+	for _, name := range names {
+		out, err := postProcessors[name](pc)
+		if err != nil {
+			log.Printf("Warning: post-processor %q failed: %v", name, err)
+			continue
+		}
+		pc.Description = out
+	}
+	return pc.Description
+}
+
+// stripMappingPass removes the debug diff-to-section mapping block, logging it in debug mode.
+func stripMappingPass(pc PostContext) (string, error) {
+	clean, mapping := extractAndStripMapping(pc.Description)
+	if pc.Config.Debug {
+		logMapping(mapping)
+	}
+	return clean, nil
+}
+
+// restoreHedgedPass reverts hedged lines to the template placeholders.
+func restoreHedgedPass(pc PostContext) (string, error) {
+	h := hedgeRestorer{phrases: pc.Config.HedgePhrases, synonyms: pc.Config.HeadingSynonyms}
+	return h.restoreHedgedSections(pc.Description, pc.Template), nil
+}
+
+// sectionLimitsPass caps each section at DIFFSCRIBE_MAX_SECTION_WORDS words.
+func sectionLimitsPass(pc PostContext) (string, error) {
+	return enforceSectionLimits(pc.Description, pc.Config.MaxSectionWords), nil
+}
+
+// markTruncatedPass marks descriptions generated from a truncated diff.
+func markTruncatedPass(pc PostContext) (string, error) {
+	if !pc.Truncated {
+		return pc.Description, nil
+	}
+	return markTruncated(pc.Description), nil
+}
+
+// stackNotePass notes that the PR builds on its parent PR when DIFFSCRIBE_STACK_CONTEXT
+// found one.
+func stackNotePass(pc PostContext) (string, error) {
+	return markStacked(pc.Description, pc.Parent), nil
+}
+
+// dependencyChangesPass appends the dependency change list.
+func dependencyChangesPass(pc PostContext) (string, error) {
+	return appendDependencyChanges(pc.Description, pc.DependencyChanges), nil
+}
+
+// fileTablePass adds the changed-files table when DIFFSCRIBE_FILE_TABLE is set.
+func fileTablePass(pc PostContext) (string, error) {
+	if !pc.Config.FileTable {
+		return pc.Description, nil
+	}
+	return insertFileTable(pc.Description, renderFileTable(pc.FileChanges), pc.Config.FileTableSection, pc.Config.HeadingSynonyms), nil
+}
+
+// fileSummariesPass appends the per-file summary list when DIFFSCRIBE_FILE_SUMMARIES is set.
+func fileSummariesPass(pc PostContext) (string, error) {
+	if !pc.Config.FileSummaries {
+		return pc.Description, nil
+	}
+	return appendFileSummaries(pc.Description, renderFileSummaries(pc.FileChanges, pc.FileSummaries)), nil
+}
+
+// packagesPass appends the per-package breakdown when DIFFSCRIBE_MONOREPO is set.
+func packagesPass(pc PostContext) (string, error) {
+	if !pc.Config.Monorepo {
+		return pc.Description, nil
+	}
+	return appendPackageSummaries(pc.Description, formatPackageSummaries(pc.Packages)), nil
+}
+
+// testChangesPass appends the changed test files when DIFFSCRIBE_TEST_CHANGES_SECTION is set.
+func testChangesPass(pc PostContext) (string, error) {
+	if !pc.Config.TestChangesSection {
+		return pc.Description, nil
+	}
+	return appendTestChanges(pc.Description, pc.FileChanges), nil
+}
+
+// symbolsPass appends the changed-symbols list when DIFFSCRIBE_SYMBOLS_SECTION is set.
+func symbolsPass(pc PostContext) (string, error) {
+	if !pc.Config.SymbolsSection {
+		return pc.Description, nil
+	}
+	return appendSymbols(pc.Description, formatSymbols(pc.Symbols)), nil
+}
+
+// apiDeltaPass appends the Go API delta when DIFFSCRIBE_GO_API_SECTION is set.
+func apiDeltaPass(pc PostContext) (string, error) {
+	if !pc.Config.GoAPISection {
+		return pc.Description, nil
+	}
+	return appendAPIDelta(pc.Description, formatAPIDelta(pc.APIDelta, defaultAPIDeltaBudget)), nil
+}
+
+// reviewChecklistPass appends the DIFFSCRIBE_REVIEW_CHECKLIST snippet.
+func reviewChecklistPass(pc PostContext) (string, error) {
+	if pc.Config.ReviewChecklistPath == "" {
+		return pc.Description, nil
+	}
+	checklist, err := os.ReadFile(pc.Config.ReviewChecklistPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read reviewer checklist: %w", err)
+	}
+	return appendReviewChecklist(pc.Description, string(checklist)), nil
+}
+
+// redactPass replaces secret-looking strings.
+func redactPass(pc PostContext) (string, error) {
+	redacted, n := redactSecrets(pc.Description)
+	if n > 0 {
+		log.Printf("Warning: redacted %d secret-looking string(s) from the generated description", n)
+	}
+	return redacted, nil
+}
+
+// keepAuthorPass restores the sections the author already filled.
+func keepAuthorPass(pc PostContext) (string, error) {
+	if !pc.Config.KeepAuthorSections {
+		return pc.Description, nil
+	}
+	merged, kept := mergeAuthorSections(pc.Description, pc.PRBody, pc.Template, pc.Config.HeadingSynonyms)
+	if len(kept) > 0 {
+		log.Printf("Kept %d section(s) the author had already filled in", len(kept))
+	}
+	return merged, nil
+}
+
+// bodyLimitPass trims the description to GitHub's body size limit, sparing author sections.
+func bodyLimitPass(pc PostContext) (string, error) {
+	var authorKeys map[string]bool
+	if pc.Config.KeepAuthorSections {
+		authorKeys = make(map[string]bool)
+		for key := range authoredSections(pc.PRBody, pc.Template, pc.Config.HeadingSynonyms) {
+			authorKeys[key] = true
+		}
+	}
+	return enforceBodyLimit(pc.Description, authorKeys, pc.Config.HeadingSynonyms, maxBodyChars), nil
+}
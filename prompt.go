@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// PromptInput is everything the description prompt is built from.
+type PromptInput struct {
+	Template    string
+	CurrentBody string
+	Diff        string
+
+	// Title and Branch are the PR title and head branch name, which often state the intent
+	// of the change (e.g. "fix/login-timeout").
+	Title  string
+	Branch string
+
+	// Context holds extra labelled sections (commit history notes, ...) placed before the diff.
+	Context []ContextBlock
+
+	// Instructions are appended to the standard numbered instructions.
+	Instructions []string
+
+	// Sections, when set, switches to structured output: the model answers with a JSON object
+	// mapping these template headings to their content, which renderStructured turns back
+	// into the template.
+	Sections []string
+}
+
+// ContextBlock is an extra labelled section of the prompt.
+type ContextBlock struct {
+	Title string
+	Text  string
+}
+
+// promptPart is one feature's contribution to the description prompt: a context block and
+// the instruction telling the model how to use it. A part without text adds nothing.
+type promptPart struct {
+	ContextBlock
+	Instruction string
+}
+
+// addParts appends the context blocks and instructions of parts, skipping empty ones.
+func (in *PromptInput) addParts(parts ...promptPart) {
+	for _, p := range parts {
+		if p.Text == "" {
+			continue
+		}
+		in.Context = append(in.Context, p.ContextBlock)
+		if p.Instruction != "" {
+			in.Instructions = append(in.Instructions, p.Instruction)
+		}
+	}
+}
+
+// addOutputInstructions appends the instructions that shape the answer rather than inform it:
+// DIFFSCRIBE_MAX_SECTION_WORDS, DIFFSCRIBE_STRUCTURED_OUTPUT (which also sets in.Sections)
+// and, with DIFFSCRIBE_DEBUG, the section-to-file mapping.
+func addOutputInstructions(cfg Config, in *PromptInput) {
+	if cfg.MaxSectionWords > 0 {
+		in.Instructions = append(in.Instructions, fmt.Sprintf("Keep each section under %d words.", cfg.MaxSectionWords))
+	}
+	if cfg.StructuredOutput {
+		if in.Sections = templateSections(in.Template); len(in.Sections) > 0 {
+			in.Instructions = append(in.Instructions, structuredInstruction(in.Sections))
+		} else {
+			log.Println("Warning: the template has no headings; DIFFSCRIBE_STRUCTURED_OUTPUT is ignored")
+		}
+	}
+	if cfg.Debug && len(in.Sections) == 0 {
+		in.Instructions = append(in.Instructions, "After the filled template, append a fenced code block with the info string `"+mappingFence+
+			"` containing a JSON object that maps each section heading you filled to the list of changed file paths that informed it.")
+	}
+}
+
+// baseInstructions is the number of standard instructions in the description prompt.
+const baseInstructions = 4
+
+// customPrompt replaces the built-in user prompt when the repository provides one; it is
+// loaded by loadPromptFiles in main.
+var customPrompt *template.Template
+
+// promptData is what a custom prompt template can reference: {{.Template}}, {{.Body}},
+// {{.Diff}}, {{.Title}}, {{.Branch}}, {{.Context}} (the rendered context sections) and
+// {{.Instructions}} (the extra numbered instructions, one per line, starting at 5).
+type promptData struct {
+	Template     string
+	Body         string
+	Diff         string
+	Title        string
+	Branch       string
+	Context      string
+	Instructions string
+}
+
+// loadPromptFiles reads the optional prompt overrides: the user prompt template at
+// promptPath and the system prompt at systemPath. Missing files keep the built-in prompts;
+// a template that does not parse is an error.
+func loadPromptFiles(promptPath, systemPath string) error {
+	if data, err := os.ReadFile(promptPath); err == nil {
+		tmpl, err := template.New("prompt").Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("invalid prompt template %s: %w", promptPath, err)
+		}
+		customPrompt = tmpl
+		log.Printf("Using the prompt template %s", promptPath)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read prompt template: %w", err)
+	}
+
+	if data, err := os.ReadFile(systemPath); err == nil {
+		if system := strings.TrimSpace(string(data)); system != "" {
+			descriptionSystemPrompt = system
+			log.Printf("Using the system prompt %s", systemPath)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read system prompt: %w", err)
+	}
+	return nil
+}
+
+// buildPrompt renders the user prompt for filling the PR template, from customPrompt when
+// one is loaded.
+func buildPrompt(in PromptInput) string {
+	var context strings.Builder
+	for _, block := range in.Context {
+		if strings.TrimSpace(block.Text) == "" {
+			continue
+		}
+		fmt.Fprintf(&context, "## %s\n%s\n\n", block.Title, strings.TrimSpace(block.Text))
+	}
+
+	var instructions strings.Builder
+	for i, instruction := range in.Instructions {
+		fmt.Fprintf(&instructions, "\n%d. %s", baseInstructions+i+1, instruction)
+	}
+
+	if customPrompt != nil {
+		var b strings.Builder
+		err := customPrompt.Execute(&b, promptData{
+			Template:     in.Template,
+			Body:         in.CurrentBody,
+			Diff:         in.Diff,
+			Title:        in.Title,
+			Branch:       in.Branch,
+			Context:      context.String(),
+			Instructions: strings.TrimPrefix(instructions.String(), "\n"),
+		})
+		if err == nil {
+			return b.String()
+		}
+		log.Printf("Warning: failed to render the prompt template, using the built-in prompt: %v", err)
+	}
+
+	var pr strings.Builder
+	if in.Title != "" || in.Branch != "" {
+		pr.WriteString("## Pull Request\n")
+		if in.Title != "" {
+			fmt.Fprintf(&pr, "Title: %s\n", in.Title)
+		}
+		if in.Branch != "" {
+			fmt.Fprintf(&pr, "Branch: %s\n", in.Branch)
+		}
+		pr.WriteString("The title and branch name often state the intent of the change; use them to frame the description, but describe only what the diff shows.\n\n")
+	}
+
+	return fmt.Sprintf(`You are helping fill out a Pull Request description template based on the code diff provided.
+
+%s## PR Template
+%s
+
+## Current PR Description (may be empty or still showing template placeholders)
+%s
+
+%s## Code Diff
+%s
+
+## Instructions
+1. Fill in ONLY the sections that can be reasonably inferred from the diff above.
+2. For any section you cannot determine from the diff, preserve the original placeholder comment (e.g., <!-- describe your changes here -->).
+3. Return ONLY the filled template content. Do not add any extra commentary outside the template.
+4. Preserve the template's exact markdown structure, headings, and checklist format.%s`, pr.String(), in.Template, in.CurrentBody, context.String(), in.Diff, instructions.String())
+}
+
+// netDiffNote explains to the model that the diff only reflects net changes, optionally
+// mentioning how many commits on the branch were reverts.
+func netDiffNote(reverts int) string {
+	note := "The diff below shows only the net change between the base branch and the PR head; intermediate changes that were later undone are not visible."
+	if reverts > 0 {
+		note += fmt.Sprintf(" %d commit(s) on this branch revert earlier work, so describe the final state only.", reverts)
+	}
+	return note
+}
+
+// netDiffPart adds netDiffNote to the prompt with DIFFSCRIBE_NET_DIFF_NOTE.
+func netDiffPart(rc *runContext) promptPart {
+	if !rc.cfg.NetDiffNote {
+		return promptPart{}
+	}
+	return promptPart{ContextBlock: ContextBlock{Title: "Diff Scope", Text: netDiffNote(revertCount(rc))}}
+}
+
+// revertCount returns the number of revert commits on the PR when DIFFSCRIBE_COUNT_REVERTS
+// is enabled, or 0 when disabled or the commit list cannot be fetched.
+func revertCount(rc *runContext) int {
+	if !rc.cfg.CountReverts {
+		return 0
+	}
+	commits, err := rc.prCommits()
+	if err != nil {
+		log.Printf("Warning: failed to fetch PR commits: %v", err)
+		return 0
+	}
+	n := countReverts(commits)
+	log.Printf("Found %d revert commit(s) among %d commits", n, len(commits))
+	return n
+}
+
+// defaultBodyBudget is the default number of bytes of the current PR body sent to the model.
+const defaultBodyBudget = 4000
+
+// trimCurrentBody shrinks the current PR body to at most budget bytes before it is put in the
+// prompt. Sections still containing placeholder comments are kept first, since they are the
+// ones left to fill; already-filled sections (e.g. from an earlier run) are dropped next, and
+// the kept sections retain their original order.
+func trimCurrentBody(body string, budget int) string {
+	if budget <= 0 || len(body) <= budget {
+		return body
+	}
+
+	sections := splitSections(body)
+	keep := make([]bool, len(sections))
+	used := 0
+	for _, placeholders := range []bool{true, false} {
+		for i, s := range sections {
+			if keep[i] || strings.Contains(s.Body, "<!--") != placeholders {
+				continue
+			}
+			if size := len(s.Heading) + len(s.Body); used+size <= budget {
+				keep[i] = true
+				used += size
+			}
+		}
+	}
+
+	var kept []section
+	omitted := 0
+	for i, s := range sections {
+		if keep[i] {
+			kept = append(kept, s)
+		} else {
+			omitted++
+		}
+	}
+	trimmed := joinSections(kept)
+	if trimmed == "" {
+		// Cut on a rune boundary so no multi-byte character is split.
+		cut := budget
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		trimmed = body[:cut]
+	}
+	return strings.TrimRight(trimmed, "\n") + fmt.Sprintf("\n\n... (%d section(s) of the current description omitted for length)", omitted)
+}
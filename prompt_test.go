@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTrimCurrentBody(t *testing.T) {
+	body := "## Summary\n<!-- What does this PR do? -->\n\n## Changes Made\n- Rewrote the parser.\n\n## Testing\n<!-- How was it tested? -->\n"
+	got := trimCurrentBody(body, 90)
+	if !strings.Contains(got, "## Summary") || !strings.Contains(got, "## Testing") {
+		t.Errorf("sections with placeholders were dropped:\n%s", got)
+	}
+	if strings.Contains(got, "Rewrote the parser") {
+		t.Errorf("the filled section was kept over the placeholder sections:\n%s", got)
+	}
+	if !strings.Contains(got, "1 section(s) of the current description omitted") {
+		t.Errorf("missing the omission note:\n%s", got)
+	}
+	if trimCurrentBody(body, len(body)) != body {
+		t.Error("a body within the budget was changed")
+	}
+}
+
+func TestTrimCurrentBodyCutsOnRuneBoundary(t *testing.T) {
+	body := strings.Repeat("Überprüfung — ", 20)
+	for budget := 1; budget < 40; budget++ {
+		if got := trimCurrentBody(body, budget); !utf8.ValidString(got) {
+			t.Fatalf("budget %d split a multi-byte character: %q", budget, got)
+		}
+	}
+}
+
+func TestAddParts(t *testing.T) {
+	var in PromptInput
+	in.addParts(
+		promptPart{ContextBlock: ContextBlock{Title: "A", Text: "a"}, Instruction: "Use A."},
+		promptPart{},
+		promptPart{ContextBlock: ContextBlock{Title: "Empty"}, Instruction: "Never added."},
+		promptPart{ContextBlock: ContextBlock{Title: "B", Text: "b"}},
+	)
+	if len(in.Context) != 2 || in.Context[0].Title != "A" || in.Context[1].Title != "B" {
+		t.Errorf("Context = %+v, want blocks A and B", in.Context)
+	}
+	if len(in.Instructions) != 1 || in.Instructions[0] != "Use A." {
+		t.Errorf("Instructions = %q, want only A's", in.Instructions)
+	}
+}
+
+func TestPromptParts(t *testing.T) {
+	changes := FileChanges{{Path: "parser.go", Additions: 3, Type: "modified"}, {Path: "parser_test.go", Additions: 5, Type: "added"}}
+	symbols := []fileSymbols{{Path: "parser.go", Added: []string{"Parse"}}}
+	deltas := []packageDelta{{Dir: "parser", Added: []string{"func Parse(s string) error"}}}
+	packages := []packageSummary{{Name: "services/api", Files: 1, Additions: 3, Summary: "Adds tracing."}}
+	parent := &PullRequest{Number: 7, Title: "Base work"}
+	on := Config{Symbols: true, GoAPIDelta: true, FileTree: true, ClassifyFiles: true}
+
+	tests := []struct {
+		name  string
+		part  promptPart
+		title string // "" when the part must be empty
+	}{
+		{"symbols", symbolsPart(on, symbols), "Changed Symbols"},
+		{"symbols off", symbolsPart(Config{SymbolsSection: true}, symbols), ""},
+		{"no symbols", symbolsPart(on, nil), ""},
+		{"api delta", apiDeltaPart(on, deltas), "Go API Changes"},
+		{"api delta off", apiDeltaPart(Config{GoAPISection: true}, deltas), ""},
+		{"file tree", fileTreePart(on, changes), "Changed Files"},
+		{"file tree off", fileTreePart(Config{}, changes), ""},
+		{"classification", classificationPart(on, changes), "Source, Test and Docs Changes"},
+		{"classification off", classificationPart(Config{}, changes), ""},
+		{"packages", packagesPart(packages), "Changes by Package"},
+		{"no packages", packagesPart(nil), ""},
+		{"parent", parentPart(parent), "Parent PR"},
+		{"no parent", parentPart(nil), ""},
+		{"repo context off", repoContextPart(Config{}), ""},
+		{"net diff off", netDiffPart(&runContext{}), ""},
+		{"milestone off", milestonePart(&runContext{}), ""},
+		{"commit messages off", commitMessagesPart(&runContext{}), ""},
+		{"linked issues off", linkedIssuesPart(&runContext{}), ""},
+	}
+	for _, tt := range tests {
+		if tt.title == "" {
+			if tt.part.Text != "" {
+				t.Errorf("%s: got %q block, want an empty part", tt.name, tt.part.Title)
+			}
+			continue
+		}
+		if tt.part.Title != tt.title || tt.part.Text == "" {
+			t.Errorf("%s: got %q block with %d bytes, want a %q block", tt.name, tt.part.Title, len(tt.part.Text), tt.title)
+		}
+	}
+}
+
+func TestMovesPart(t *testing.T) {
+	diff := "diff --git a/old.go b/new.go\nsimilarity index 100%\nrename from old.go\nrename to new.go\n" +
+		"diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-a\n+b\n"
+	if part, got := movesPart(Config{}, diff); part.Text != "" || got != diff {
+		t.Errorf("disabled: got part %q and a changed diff", part.Title)
+	}
+	part, got := movesPart(Config{SummarizeMoves: true}, diff)
+	if part.Title != "Renames and Moves" || !strings.Contains(part.Text, "new.go") {
+		t.Errorf("part = %+v, want the rename of old.go listed", part)
+	}
+	if strings.Contains(got, "old.go") || !strings.Contains(got, "main.go") {
+		t.Errorf("diff without unchanged moves = %q", got)
+	}
+}
+
+func TestAddOutputInstructions(t *testing.T) {
+	in := PromptInput{Template: "## Summary\n<!-- what -->\n\n## Testing\n<!-- how -->\n"}
+	addOutputInstructions(Config{MaxSectionWords: 50, StructuredOutput: true, Debug: true}, &in)
+	if len(in.Sections) != 2 {
+		t.Errorf("Sections = %q, want the template's two headings", in.Sections)
+	}
+	// Structured output replaces the debug mapping, which has no place in a JSON answer.
+	if len(in.Instructions) != 2 || !strings.Contains(in.Instructions[0], "under 50 words") {
+		t.Errorf("Instructions = %q, want the word limit and the structured output instruction", in.Instructions)
+	}
+
+	in = PromptInput{Template: "no headings"}
+	addOutputInstructions(Config{StructuredOutput: true, Debug: true}, &in)
+	if len(in.Sections) != 0 || len(in.Instructions) != 1 || !strings.Contains(in.Instructions[0], mappingFence) {
+		t.Errorf("without headings: Sections = %q, Instructions = %q, want only the debug mapping", in.Sections, in.Instructions)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Generator is an inference backend. Generate performs a single chat completion call against
+// model; retries and failover are handled by complete, on top of it.
+type Generator interface {
+	Generate(model string, creq completionRequest) (GenerationResult, error)
+}
+
+// generators builds each supported provider (DIFFSCRIBE_PROVIDER /
+// DIFFSCRIBE_FALLBACK_PROVIDER) from the config.
+var generators = map[string]func(cfg Config) (Generator, error){
+	defaultProvider: func(cfg Config) (Generator, error) {
+		return openAIChat{name: "GitHub Models", baseURL: githubModelsBase, token: cfg.ModelsToken}, nil
+	},
+	"openai": func(cfg Config) (Generator, error) {
+		if cfg.OpenAIKey == "" {
+			return nil, fmt.Errorf("the openai provider requires OPENAI_API_KEY")
+		}
+		return openAIChat{name: "OpenAI", baseURL: openAIBase, token: cfg.OpenAIKey}, nil
+	},
+	"anthropic": func(cfg Config) (Generator, error) {
+		if cfg.AnthropicKey == "" {
+			return nil, fmt.Errorf("the anthropic provider requires ANTHROPIC_API_KEY")
+		}
+		return anthropicChat{apiKey: cfg.AnthropicKey}, nil
+	},
+	"azure-openai": func(cfg Config) (Generator, error) {
+		if cfg.AzureEndpoint == "" || cfg.AzureKey == "" || cfg.AzureDeployment == "" {
+			return nil, fmt.Errorf("the azure-openai provider requires AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY and DIFFSCRIBE_AZURE_DEPLOYMENT")
+		}
+		return openAIChat{name: "Azure OpenAI", baseURL: cfg.AzureEndpoint, token: cfg.AzureKey, azureAPIVersion: cfg.AzureAPIVersion}, nil
+	},
+	"bedrock": func(cfg Config) (Generator, error) {
+		if cfg.AWSRegion == "" {
+			return nil, fmt.Errorf("the bedrock provider requires AWS_REGION")
+		}
+		creds, err := loadAWSCredentials()
+		if err != nil {
+			return nil, err
+		}
+		return bedrockChat{region: cfg.AWSRegion, creds: creds}, nil
+	},
+	"gemini": func(cfg Config) (Generator, error) {
+		if cfg.GeminiKey == "" {
+			return nil, fmt.Errorf("the gemini provider requires GEMINI_API_KEY")
+		}
+		return geminiChat{name: "Gemini", apiKey: cfg.GeminiKey, safety: cfg.GeminiSafety}, nil
+	},
+	"vertex": func(cfg Config) (Generator, error) {
+		if cfg.GoogleCredentials == "" {
+			return nil, fmt.Errorf("the vertex provider requires GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		sa, err := loadServiceAccount(cfg.GoogleCredentials)
+		if err != nil {
+			return nil, err
+		}
+		project := cfg.VertexProject
+		if project == "" {
+			project = sa.ProjectID
+		}
+		token, err := sa.accessToken(time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate with Vertex AI: %w", err)
+		}
+		return geminiChat{name: "Vertex AI", accessToken: token, project: project, location: cfg.VertexLocation, safety: cfg.GeminiSafety}, nil
+	},
+	// openai-compatible covers any other OpenAI-style endpoint: LiteLLM proxies, vLLM,
+	// LM Studio, corporate gateways.
+	"openai-compatible": func(cfg Config) (Generator, error) {
+		if cfg.BaseURL == "" || len(cfg.Models) == 0 {
+			return nil, fmt.Errorf("the openai-compatible provider requires DIFFSCRIBE_BASE_URL and DIFFSCRIBE_MODEL")
+		}
+		return openAIChat{name: "OpenAI-compatible", baseURL: strings.TrimRight(cfg.BaseURL, "/"), token: cfg.APIKey}, nil
+	},
+	// ollama speaks the OpenAI-compatible API that Ollama and llama.cpp servers expose, so no
+	// code leaves the runner's network.
+	"ollama": func(cfg Config) (Generator, error) {
+		return openAIChat{name: "Ollama", baseURL: strings.TrimRight(cfg.OllamaURL, "/"), token: cfg.OllamaKey}, nil
+	},
+}
+
+// providerModels is the default model of each provider with a fixed default.
+var providerModels = map[string]string{
+	defaultProvider: "gpt-4o-mini",
+	"openai":        "gpt-4o-mini",
+	"anthropic":     "claude-3-5-haiku-latest",
+}
+
+// newGenerator builds the Generator for the named provider.
+func newGenerator(provider string, cfg Config) (Generator, error) {
+	build, ok := generators[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q", provider)
+	}
+	return build(cfg)
+}
+
+// providerLabels names each provider in the comment footer.
+var providerLabels = map[string]string{
+	defaultProvider:     "GitHub Models",
+	"openai":            "OpenAI",
+	"anthropic":         "Anthropic",
+	"azure-openai":      "Azure OpenAI",
+	"ollama":            "Ollama",
+	"bedrock":           "Amazon Bedrock",
+	"gemini":            "Gemini",
+	"vertex":            "Vertex AI",
+	"openai-compatible": "an OpenAI-compatible API",
+}
+
+// newModelChain builds the models tried in order: DIFFSCRIBE_MODEL (or the provider's default
+// model) on DIFFSCRIBE_PROVIDER, then DIFFSCRIBE_FALLBACK_MODEL on DIFFSCRIBE_FALLBACK_PROVIDER.
+// Repeated entries are dropped.
+func newModelChain(cfg Config) ([]modelLink, error) {
+	g, err := newGenerator(cfg.Provider, cfg)
+	if err != nil {
+		return nil, err
+	}
+	models := cfg.Models
+	if len(models) == 0 {
+		models = []string{providerModel(cfg)}
+	}
+	var chain []modelLink
+	for _, model := range models {
+		chain = appendModelLink(chain, modelLink{provider: cfg.Provider, endpoint: providerEndpoint(cfg.Provider, cfg), model: model, generator: g})
+	}
+	if cfg.FallbackModel != "" {
+		fg, err := newGenerator(cfg.FallbackProvider, cfg)
+		if err != nil {
+			return nil, err
+		}
+		chain = appendModelLink(chain, modelLink{provider: cfg.FallbackProvider, endpoint: providerEndpoint(cfg.FallbackProvider, cfg), model: cfg.FallbackModel, generator: fg})
+	}
+	return chain, nil
+}
+
+// providerEndpoint is the server provider's requests go to, telling apart servers that host
+// models under the same names (e.g. two OpenAI-compatible gateways).
+func providerEndpoint(provider string, cfg Config) string {
+	switch provider {
+	case defaultProvider:
+		return githubModelsBase
+	case "openai":
+		return openAIBase
+	case "anthropic":
+		return anthropicBase
+	case "azure-openai":
+		return cfg.AzureEndpoint
+	case "bedrock":
+		return "bedrock-runtime." + cfg.AWSRegion + ".amazonaws.com"
+	case "gemini":
+		return geminiBase
+	case "vertex":
+		return cfg.VertexLocation + "-aiplatform.googleapis.com/" + cfg.VertexProject
+	case "openai-compatible":
+		return strings.TrimRight(cfg.BaseURL, "/")
+	case "ollama":
+		return strings.TrimRight(cfg.OllamaURL, "/")
+	}
+	return ""
+}
+
+// appendModelLink appends link to chain unless it is already there.
+func appendModelLink(chain []modelLink, link modelLink) []modelLink {
+	for _, l := range chain {
+		if l.provider == link.provider && l.model == link.model {
+			return chain
+		}
+	}
+	return append(chain, link)
+}
+
+// providerModel is the default model of cfg's provider. Azure OpenAI addresses the configured
+// deployment instead, and Ollama, Bedrock and Gemini the model configured for them.
+func providerModel(cfg Config) string {
+	switch cfg.Provider {
+	case "azure-openai":
+		return cfg.AzureDeployment
+	case "ollama":
+		return cfg.OllamaModel
+	case "bedrock":
+		return cfg.BedrockModel
+	case "gemini", "vertex":
+		return cfg.GeminiModel
+	}
+	return providerModels[cfg.Provider]
+}
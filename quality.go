@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// qualityAssessment is the model's structured rating of a generated description.
+type qualityAssessment struct {
+	Score           int      `json:"score"`
+	MissingSections []string `json:"missing_sections"`
+}
+
+// assessDescription asks the model to rate how complete description is (0–100) and which
+// template sections still need human input.
+func assessDescription(description, template string) (qualityAssessment, error) {
+	prompt := fmt.Sprintf(`Rate how complete the following Pull Request description is with respect to its template.
+
+## PR Template
+%s
+
+## PR Description
+%s
+
+## Instructions
+Respond with a JSON object only, in this exact shape:
+{"score": <integer 0-100>, "missing_sections": [<template section headings that are empty, still contain placeholder comments, or need human input>]}`, template, description)
+
+	content, err := chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: "You are a meticulous reviewer who evaluates Pull Request descriptions."},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens:   300,
+		Temperature: 0,
+		JSON:        true,
+	})
+	if err != nil {
+		return qualityAssessment{}, err
+	}
+	return parseQualityAssessment(content)
+}
+
+// parseQualityAssessment decodes the model's JSON reply, tolerating a surrounding code fence.
+func parseQualityAssessment(content string) (qualityAssessment, error) {
+	var qa qualityAssessment
+	if err := json.Unmarshal([]byte(stripCodeFence(content)), &qa); err != nil {
+		return qa, fmt.Errorf("invalid quality assessment JSON: %w", err)
+	}
+	if qa.Score < 0 {
+		qa.Score = 0
+	} else if qa.Score > 100 {
+		qa.Score = 100
+	}
+	return qa, nil
+}
+
+// stripCodeFence removes a single markdown code fence wrapping text, if present.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+		text = text[nl+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// formatQualityNote renders the assessment for the completion comment.
+func formatQualityNote(qa qualityAssessment) string {
+	note := fmt.Sprintf("**Description quality score:** %d/100", qa.Score)
+	if len(qa.MissingSections) == 0 {
+		return note
+	}
+	note += "\n\nSections that still need your input:"
+	for _, s := range qa.MissingSections {
+		note += "\n- " + s
+	}
+	return note
+}
+
+// qualityNote scores the filled description for the completion comment with
+// DIFFSCRIBE_QUALITY_SCORE.
+func qualityNote(rc *runContext, template string) string {
+	if !rc.cfg.QualityScore {
+		return ""
+	}
+	qa, err := assessDescription(rc.description, template)
+	if err != nil {
+		log.Printf("Warning: failed to assess description quality: %v", err)
+		return ""
+	}
+	log.Printf("Description quality score: %d/100 (%d section(s) need input)", qa.Score, len(qa.MissingSections))
+	return formatQualityNote(qa)
+}
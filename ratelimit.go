@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by every outbound GitHub and Models request. Waiters
+// reserve a token under the lock and sleep outside it, so it is safe for concurrent use.
+// A nil *rateLimiter never blocks.
+type rateLimiter struct {
+	mu       sync.Mutex
+	perSec   float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+
+	requests int
+	delayed  int
+	waited   time.Duration
+	maxWait  time.Duration
+}
+
+// rateLimitStats summarises how long requests were held back by the limiter.
+type rateLimitStats struct {
+	Requests    int   `json:"requests"`
+	Delayed     int   `json:"delayed"`
+	TotalWaitMs int64 `json:"total_wait_ms"`
+	MaxWaitMs   int64 `json:"max_wait_ms"`
+}
+
+// newRateLimiter returns a limiter allowing rpm requests per minute with bursts of up to
+// burst requests, or nil when rpm is not positive.
+func newRateLimiter(rpm, burst int) *rateLimiter {
+	if rpm <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		perSec:   float64(rpm) / 60,
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent and returns how long it waited.
+func (l *rateLimiter) Wait() time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSec
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+	l.tokens--
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.perSec * float64(time.Second))
+	}
+	l.requests++
+	if wait > 0 {
+		l.delayed++
+	}
+	l.waited += wait
+	if wait > l.maxWait {
+		l.maxWait = wait
+	}
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+	return wait
+}
+
+// Stats returns a snapshot of the limiter's wait metrics.
+func (l *rateLimiter) Stats() rateLimitStats {
+	if l == nil {
+		return rateLimitStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return rateLimitStats{
+		Requests:    l.requests,
+		Delayed:     l.delayed,
+		TotalWaitMs: l.waited.Milliseconds(),
+		MaxWaitMs:   l.maxWait.Milliseconds(),
+	}
+}
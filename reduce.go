@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Diff reduction strategies selectable with DIFFSCRIBE_TRUNCATE_STRATEGY.
+const (
+	strategyHead       = "head"       // keep the beginning of the diff
+	strategyHeadTail   = "head-tail"  // keep the beginning and the end, dropping the middle
+	strategyPrioritize = "prioritize" // keep whole files, most relevant first
+	strategyMapReduce  = "map-reduce" // summarise chunks with the model and describe the summaries
+)
+
+// truncateStrategies lists the valid DIFFSCRIBE_TRUNCATE_STRATEGY values.
+var truncateStrategies = []string{strategyHead, strategyHeadTail, strategyPrioritize, strategyMapReduce}
+
+// reduceDiff shrinks diff to fit maxSize bytes using the configured strategy and reports
+// whether content was dropped. Diffs that already fit are returned unchanged.
+func reduceDiff(diff string, maxSize int, cfg Config) (string, bool) {
+	if len(diff) <= maxSize {
+		return diff, false
+	}
+	switch cfg.TruncateStrategy {
+	case strategyHeadTail:
+		return headTailDiff(diff, maxSize, cfg.TruncationNotice), true
+	case strategyPrioritize:
+		return prioritizeDiff(diff, maxSize, cfg.TruncationNotice)
+	case strategyMapReduce:
+		reduced, err := mapReduceDiff(diff, maxSize)
+		if err != nil {
+			log.Printf("Warning: map-reduce summarisation failed, falling back to head truncation: %v", err)
+			break
+		}
+		return truncateDiff(reduced, maxSize, cfg.TruncationNotice)
+	}
+	return truncateDiff(diff, maxSize, cfg.TruncationNotice)
+}
+
+// headTailDiff keeps roughly the first two thirds and the last third of maxSize bytes, cut on
+// file and hunk boundaries, so both the start of the change and its final files stay visible.
+func headTailDiff(diff string, maxSize int, notice string) string {
+	headSize := maxSize * 2 / 3
+	tailSize := maxSize - headSize
+
+	head := cutDiff(diff, headSize)
+	tail := tailOfDiff(diff, tailSize)
+	omitted := len(diff) - len(head) - len(tail)
+	return head + fmt.Sprintf("\n... (%d bytes omitted) ...\n\n", omitted) + tail + "\n\n" + notice + "\n" + truncatedMarker
+}
+
+// tailOfDiff returns a suffix of diff of at most size bytes made of whole files, preceded by
+// the header and trailing hunks of the file before them when those fit too.
+func tailOfDiff(diff string, size int) string {
+	files := splitDiffFiles(diff)
+	n := 0
+	i := len(files)
+	for i > 0 && n+len(files[i-1].Text) <= size {
+		i--
+		n += len(files[i].Text)
+	}
+	tail := joinDiffFiles(files[i:])
+	if i == 0 || files[i-1].Path == "" {
+		return tail
+	}
+	header, hunks := splitHunks(files[i-1].Text)
+	j := len(hunks)
+	for j > 0 && n+len(header)+len(hunks[j-1]) <= size {
+		j--
+		n += len(hunks[j])
+	}
+	if j == len(hunks) {
+		return tail
+	}
+	return header + strings.Join(hunks[j:], "") + tail
+}
+
+// prioritizeDiff keeps whole file blocks in order of filePriority until maxSize is used up.
+// A file that no longer fits whole keeps as many of its leading hunks as fit, and the files
+// cut short or left out are listed. The kept files retain their original order.
+func prioritizeDiff(diff string, maxSize int, notice string) (string, bool) {
+	files := splitDiffFiles(diff)
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return filePriority(files[order[a]].Path) < filePriority(files[order[b]].Path)
+	})
+
+	keep := make([]bool, len(files))
+	partial := make(map[int]string)
+	budget := maxSize
+	for _, i := range order {
+		if len(files[i].Text) <= budget {
+			keep[i] = true
+			budget -= len(files[i].Text)
+		} else if text := leadingHunks(files[i].Text, budget); text != "" {
+			keep[i] = true
+			partial[i] = text
+			budget -= len(text)
+		}
+	}
+
+	var kept []fileDiff
+	var omitted strings.Builder
+	for i, f := range files {
+		if text, ok := partial[i]; ok {
+			_, hunks := splitHunks(f.Text)
+			_, keptHunks := splitHunks(text)
+			fmt.Fprintf(&omitted, "- %s (+%d/-%d lines; only the first %d of %d hunks shown)\n", f.Path, f.Additions, f.Deletions, len(keptHunks), len(hunks))
+			f.Text = text
+		}
+		if keep[i] {
+			kept = append(kept, f)
+		} else if f.Path != "" {
+			fmt.Fprintf(&omitted, "- %s (+%d/-%d lines)\n", f.Path, f.Additions, f.Deletions)
+		}
+	}
+	if len(kept) == 0 {
+		return truncateDiff(diff, maxSize, notice)
+	}
+	return joinDiffFiles(kept) + "\n\nFiles omitted or cut short in this diff:\n" + omitted.String() + "\n" + notice + "\n" + truncatedMarker, true
+}
+
+// leadingHunks returns the header and as many leading hunks of a file block as fit in size
+// bytes, or "" when not even the first hunk fits.
+func leadingHunks(text string, size int) string {
+	header, hunks := splitHunks(text)
+	n := len(header)
+	kept := 0
+	for kept < len(hunks) && n+len(hunks[kept]) <= size {
+		n += len(hunks[kept])
+		kept++
+	}
+	if kept == 0 {
+		return ""
+	}
+	return header + strings.Join(hunks[:kept], "")
+}
+
+// filePriority ranks a path by how much it usually tells about a change: source code first,
+// then tests, then docs and configuration, then lockfiles, and vendored or generated files
+// last.
+func filePriority(p string) int {
+	base := strings.ToLower(path.Base(p))
+	switch {
+	case strings.HasPrefix(p, "vendor/") || strings.Contains(p, "/vendor/") || strings.Contains(p, "node_modules/"),
+		strings.Contains(base, ".generated.") || strings.HasSuffix(base, ".pb.go") || strings.HasSuffix(base, ".min.js"):
+		return 4
+	case isLockfile(p) || strings.HasSuffix(base, ".lock") || strings.HasSuffix(base, "-lock.json"):
+		return 3
+	case strings.HasSuffix(base, ".md") || strings.HasSuffix(base, ".txt") || strings.HasSuffix(base, ".json") ||
+		strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".toml"):
+		return 2
+	case isTestFile(p):
+		return 1
+	}
+	return 0
+}
+
+// maxCondenseRounds bounds how often mapReduceDiff summarises its own summaries when they
+// still do not fit.
+const maxCondenseRounds = 3
+
+// mapReduceDiff splits diff into chunks of whole files of at most chunkSize bytes, has the
+// model summarise each chunk, and returns the concatenated summaries in place of the diff.
+// Summaries that together still exceed chunkSize are condensed again, so files late in a
+// very large diff are not cut off.
+func mapReduceDiff(diff string, chunkSize int) (string, error) {
+	summaries, err := summarizeChunks(diffChunks(diff, chunkSize), "diff chunk", summarizeDiffChunk)
+	for round := 1; err == nil && len(summaries) > chunkSize && round <= maxCondenseRounds; round++ {
+		log.Printf("Chunk summaries take %d chars; condensing them (round %d/%d)...", len(summaries), round, maxCondenseRounds)
+		summaries, err = summarizeChunks(textChunks(summaries, chunkSize), "summary chunk", condenseSummaries)
+	}
+	return summaries, err
+}
+
+// summarizeChunks summarises each chunk with summarize and concatenates the results under
+// "Diff part" headings; what names the chunks in log lines and errors.
+func summarizeChunks(chunks []string, what string, summarize func(chunk string, part, total int) (string, error)) (string, error) {
+	var b strings.Builder
+	for i, chunk := range chunks {
+		log.Printf("Summarising %s %d/%d (%d chars)...", what, i+1, len(chunks), len(chunk))
+		summary, err := summarize(chunk, i+1, len(chunks))
+		if err != nil {
+			return "", fmt.Errorf("%s %d/%d: %w", what, i+1, len(chunks), err)
+		}
+		fmt.Fprintf(&b, "### Diff part %d of %d (summarised)\n%s\n\n", i+1, len(chunks), strings.TrimSpace(summary))
+	}
+	return b.String(), nil
+}
+
+// textChunks groups the paragraphs of text into chunks of at most size bytes; a single
+// paragraph larger than size is split over several chunks, so no text is lost.
+func textChunks(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, para := range strings.SplitAfter(text, "\n\n") {
+		for _, piece := range splitText(para, size) {
+			if current.Len() > 0 && current.Len()+len(piece) > size {
+				chunks = append(chunks, current.String())
+				current.Reset()
+			}
+			current.WriteString(piece)
+		}
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// splitText splits text into pieces of at most size bytes, after a newline where it can and
+// otherwise on a rune boundary.
+func splitText(text string, size int) []string {
+	var pieces []string
+	for len(text) > size && size > 0 {
+		cut := strings.LastIndexByte(text[:size], '\n') + 1
+		if cut == 0 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(text)
+			}
+		}
+		pieces = append(pieces, text[:cut])
+		text = text[cut:]
+	}
+	return append(pieces, text)
+}
+
+// diffChunks groups file blocks into chunks of at most size bytes; a single file larger than
+// size is cut to fit.
+func diffChunks(diff string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	for _, f := range splitDiffFiles(diff) {
+		text := f.Text
+		if len(text) > size {
+			text, _ = truncateDiff(text, size, "... (file diff truncated)")
+		}
+		if current.Len() > 0 && current.Len()+len(text) > size {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(text)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// summarizeDiffChunk asks the model for a compact, file-by-file summary of one diff chunk.
+func summarizeDiffChunk(chunk string, part, total int) (string, error) {
+	prompt := fmt.Sprintf(`This is part %d of %d of a Pull Request diff. Summarise what it changes, file by file, as short bullet points. Mention new or changed functions, types, configuration and behaviour; skip formatting-only changes. Return only the bullet points.
+
+%s`, part, total, chunk)
+
+	return summaryCompletion(prompt)
+}
+
+// condenseSummaries asks the model to merge the chunk summaries of one part into a shorter
+// file-by-file summary.
+func condenseSummaries(summaries string, part, total int) (string, error) {
+	prompt := fmt.Sprintf(`These are summaries of part %d of %d of a large Pull Request diff. Merge them into a shorter file-by-file summary as bullet points. Keep every file and every change to behaviour, APIs or configuration; group similar minor changes. Return only the bullet points.
+
+%s`, part, total, summaries)
+
+	return summaryCompletion(prompt)
+}
+
+// summaryCompletion runs one map-reduce summarisation prompt.
+func summaryCompletion(prompt string) (string, error) {
+	return chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: "You are an expert software engineer who summarises code changes precisely and concisely."},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens:   600,
+		Temperature: 0.2,
+	})
+}
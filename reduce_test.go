@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTextChunks(t *testing.T) {
+	long := strings.Repeat("- summarised a changed line\n", 20) + strings.Repeat("é", 100) + "\n\n"
+	text := "### Diff part 1 of 2 (summarised)\n- short\n\n" + long + "### Diff part 2 of 2 (summarised)\n- last\n\n"
+	const size = 120
+	chunks := textChunks(text, size)
+	if got := strings.Join(chunks, ""); got != text {
+		t.Fatalf("chunks do not add up to the text:\n%s", got)
+	}
+	for i, chunk := range chunks {
+		if len(chunk) > size {
+			t.Errorf("chunk %d is %d bytes, over %d", i, len(chunk), size)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk %d splits a multi-byte character: %q", i, chunk)
+		}
+	}
+	if len(chunks) < len(long)/size {
+		t.Errorf("%d chunks for a %d byte paragraph, want it split over several", len(chunks), len(long))
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// releaseNoteHeading is the heading of the release note section.
+const releaseNoteHeading = "## Release note"
+
+// releaseNoteFence is the info string of the code block release tooling scrapes.
+const releaseNoteFence = "release-note"
+
+// generateReleaseNote asks the model for a single user-facing release note line describing
+// the change in description.
+func generateReleaseNote(description string) (string, error) {
+	prompt := fmt.Sprintf(`Write ONE release note line for the change described by this Pull Request description.
+
+## PR Description
+%s
+
+## Instructions
+1. Write for end users of the product, not for its developers: describe the visible effect, not the implementation.
+2. Avoid internal jargon, file names, function names and ticket numbers.
+3. Start with a past-tense verb such as "Added", "Fixed", "Improved" or "Removed", e.g. "Added dark mode to the settings page".
+4. If the change has no user-visible effect, write "Internal changes only".
+5. Return ONLY the line, without bullet markers, quotes or a trailing period.`, description)
+
+	note, err := chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: "You are a technical writer who writes concise, user-facing release notes."},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens:   100,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+	note = strings.TrimSpace(strings.SplitN(stripCodeFence(note), "\n", 2)[0])
+	return strings.TrimRight(strings.Trim(strings.TrimLeft(note, "-*• "), `"`), "."), nil
+}
+
+// appendReleaseNote adds note to body as a "## Release note" section or, with format
+// "block", as a release-note code block, unless the body already has one.
+func appendReleaseNote(body, note, format string) string {
+	if note == "" || strings.Contains(body, "```"+releaseNoteFence) {
+		return body
+	}
+	if _, exists := sectionsByKey(body, nil)[sectionKey(strings.TrimLeft(releaseNoteHeading, "# "))]; exists {
+		return body
+	}
+	body = strings.TrimRight(body, "\n") + "\n\n"
+	if format == "block" {
+		return body + "```" + releaseNoteFence + "\n" + note + "\n```\n"
+	}
+	return body + releaseNoteHeading + "\n" + note + "\n"
+}
+
+// releaseNoteMemo holds the run's release note. It is generated from the first description
+// it is asked for and stored in the description cache next to it, so cache hits and
+// DIFFSCRIBE_COMPARE_MODELS outputs cost no further model calls.
+type releaseNoteMemo struct {
+	cache Cache
+	key   string // cache key of the note; "" leaves the cache alone
+	note  string
+	err   error
+	done  bool
+}
+
+// newReleaseNoteMemo returns the memo for the description cached under descriptionKey.
+func newReleaseNoteMemo(cache Cache, descriptionKey string) *releaseNoteMemo {
+	m := &releaseNoteMemo{cache: cache}
+	if descriptionKey != "" && cache != nil {
+		m.key = cacheKey("release-note", descriptionKey)
+	}
+	return m
+}
+
+// get returns the release note, generating it from description on first use.
+func (m *releaseNoteMemo) get(description string) (string, error) {
+	if m.done {
+		return m.note, m.err
+	}
+	m.done = true
+	if m.key != "" {
+		if note, ok := m.cache.Get(m.key); ok {
+			m.note = note
+			return note, nil
+		}
+	}
+	if m.note, m.err = generateReleaseNote(description); m.err == nil && m.key != "" {
+		m.cache.Set(m.key, m.note)
+	}
+	return m.note, m.err
+}
+
+// releaseNotePass appends the release note when DIFFSCRIBE_RELEASE_NOTE is set.
+func releaseNotePass(pc PostContext) (string, error) {
+	if !pc.Config.ReleaseNote {
+		return pc.Description, nil
+	}
+	memo := pc.ReleaseNote
+	if memo == nil {
+		memo = newReleaseNoteMemo(nil, "")
+	}
+	note, err := memo.get(pc.Description)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate release note: %w", err)
+	}
+	return appendReleaseNote(pc.Description, note, pc.Config.ReleaseNoteFormat), nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestReleaseNoteMemo(t *testing.T) {
+	cache := &fileCache{dir: t.TempDir()}
+	key := cacheKey("release-note", "description-key")
+	cache.Set(key, "Added dark mode")
+
+	// A cached note is reused without a model call, and later passes reuse the first note
+	// even when the cache no longer has it.
+	memo := newReleaseNoteMemo(cache, "description-key")
+	for i := 0; i < 2; i++ {
+		for _, description := range []string{"## Summary\nPrimary.", "## Summary\nComparison."} {
+			got, err := releaseNotePass(PostContext{
+				Config:      Config{ReleaseNote: true},
+				Description: description,
+				ReleaseNote: memo,
+			})
+			if err != nil {
+				t.Fatalf("releaseNotePass: %v", err)
+			}
+			if want := description + "\n\n## Release note\nAdded dark mode\n"; got != want {
+				t.Errorf("releaseNotePass(%q) = %q, want %q", description, got, want)
+			}
+		}
+		cache.Set(key, "Changed")
+	}
+}
+
+func TestNewReleaseNoteMemo(t *testing.T) {
+	cache := &fileCache{dir: t.TempDir()}
+	if m := newReleaseNoteMemo(cache, ""); m.key != "" {
+		t.Errorf("key without a description key = %q, want empty", m.key)
+	}
+	if m := newReleaseNoteMemo(nil, "k"); m.key != "" {
+		t.Errorf("key without a cache = %q, want empty", m.key)
+	}
+	if m := newReleaseNoteMemo(cache, "k"); m.key == "" || m.key == "k" {
+		t.Errorf("key = %q, want a key distinct from the description's", m.key)
+	}
+}
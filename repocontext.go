@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// maxRepoExcerptBytes bounds each README and CONTRIBUTING excerpt added to the prompt.
+const maxRepoExcerptBytes = 2000
+
+// readmePaths and contributingPaths are where the repository's README and contribution
+// guide are looked for, in order.
+var (
+	readmePaths       = []string{"README.md", "README.rst", "README", "docs/README.md"}
+	contributingPaths = []string{"CONTRIBUTING.md", ".github/CONTRIBUTING.md", "docs/CONTRIBUTING.md", "CONTRIBUTING.rst"}
+)
+
+// conventionHeadingPattern matches CONTRIBUTING headings about the conventions a PR
+// description should follow.
+var conventionHeadingPattern = regexp.MustCompile(`(?i)convention|style|guideline|commit|pull request|\bprs?\b|terminology|glossary|naming|changelog`)
+
+// badgeLinePattern matches README lines holding only badges, images or HTML tags.
+var badgeLinePattern = regexp.MustCompile(`^\s*(?:(?:\[?!\[[^\]]*\]\([^)]*\)\]?(?:\([^)]*\))?\s*)+|<[^>]+>\s*)$`)
+
+// readRepoFile returns the first of paths found in the checked-out repository, or else on
+// its default branch via the contents API, with the path it came from.
+func readRepoFile(cfg Config, paths []string) (string, string) {
+	for _, p := range paths {
+		if data, err := os.ReadFile(p); err == nil {
+			return string(data), p
+		}
+	}
+	for _, p := range paths {
+		content, found, err := fetchFileAtRef(cfg.Repository, p, "", cfg.GitHubToken)
+		if err != nil {
+			log.Printf("Warning: failed to fetch %s: %v", p, err)
+			return "", ""
+		}
+		if found {
+			return content, p
+		}
+	}
+	return "", ""
+}
+
+// readmeExcerpt returns the README's first section: its title and introduction up to the
+// next heading, without badges and HTML comments. A title with no text of its own takes the
+// following section along.
+func readmeExcerpt(readme string) string {
+	var b strings.Builder
+	for _, s := range splitSections(htmlCommentPattern.ReplaceAllString(readme, "")) {
+		var lines []string
+		for _, line := range strings.Split(s.Body, "\n") {
+			if !badgeLinePattern.MatchString(line) {
+				lines = append(lines, line)
+			}
+		}
+		body := strings.Join(lines, "\n")
+		b.WriteString(s.Heading + body)
+		if s.Heading != "" && strings.TrimSpace(body) != "" {
+			break
+		}
+	}
+	return clipExcerpt(b.String())
+}
+
+// contributingExcerpt returns the CONTRIBUTING sections about conventions (commit style,
+// pull requests, naming, terminology, ...), or the start of the guide when none is titled
+// that way.
+func contributingExcerpt(guide string) string {
+	guide = htmlCommentPattern.ReplaceAllString(guide, "")
+	var b strings.Builder
+	for _, s := range splitSections(guide) {
+		if conventionHeadingPattern.MatchString(s.Title()) {
+			b.WriteString(s.Heading + s.Body)
+		}
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return clipExcerpt(guide)
+	}
+	return clipExcerpt(b.String())
+}
+
+// clipExcerpt trims text and cuts it to maxRepoExcerptBytes at a line boundary.
+func clipExcerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) <= maxRepoExcerptBytes {
+		return text
+	}
+	cut := strings.LastIndexByte(text[:maxRepoExcerptBytes], '\n')
+	if cut <= 0 {
+		cut = maxRepoExcerptBytes
+	}
+	return strings.ToValidUTF8(text[:cut], "") + "\n..."
+}
+
+// repoContext renders the README and CONTRIBUTING excerpts for the prompt, or "" when the
+// repository has neither.
+func repoContext(cfg Config) string {
+	var parts []string
+	if readme, p := readRepoFile(cfg, readmePaths); readme != "" {
+		if excerpt := readmeExcerpt(readme); excerpt != "" {
+			parts = append(parts, "From "+p+":\n"+excerpt)
+		}
+	}
+	if guide, p := readRepoFile(cfg, contributingPaths); guide != "" {
+		if excerpt := contributingExcerpt(guide); excerpt != "" {
+			parts = append(parts, "From "+p+":\n"+excerpt)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// repoContextPart adds the README and CONTRIBUTING excerpts to the prompt with
+// DIFFSCRIBE_REPO_CONTEXT.
+func repoContextPart(cfg Config) promptPart {
+	if !cfg.RepoContext {
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Project Context", Text: repoContext(cfg)},
+		Instruction:  "Use the project's terminology and follow the conventions in the project context above; do not describe the project itself.",
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// redactedPlaceholder replaces any detected secret value.
+const redactedPlaceholder = "[REDACTED]"
+
+// secretPatterns are the credential shapes DiffScribe refuses to publish. When a pattern has
+// a "secret" capture group only that group is redacted, so surrounding context stays readable.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`),
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{20,}\b`),
+	regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?key|auth[_-]?token|client[_-]?secret)["']?\s*[:=]\s*["']?(?P<secret>[^\s"'<>]{8,})`),
+}
+
+// redactSecrets replaces every secret-looking string in text and reports how many were found.
+func redactSecrets(text string) (string, int) {
+	total := 0
+	for _, re := range secretPatterns {
+		var n int
+		text, n = redactMatches(re, text)
+		total += n
+	}
+	return text, total
+}
+
+// redactMatches replaces every match of re (or its "secret" group) with redactedPlaceholder,
+// skipping spans an earlier pattern already redacted.
+func redactMatches(re *regexp.Regexp, text string) (string, int) {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+	group := re.SubexpIndex("secret")
+
+	var b strings.Builder
+	last, count := 0, 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		if text[start:end] == redactedPlaceholder {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(redactedPlaceholder)
+		last = end
+		count++
+	}
+	b.WriteString(text[last:])
+	return b.String(), count
+}
+
+// redactInput masks secrets in every message sent to a model; it is configured from
+// DIFFSCRIBE_REDACT_INPUT in main.
+var redactInput bool
+
+// tokenCandidatePattern matches long runs of token characters that may be random secrets.
+var tokenCandidatePattern = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+
+// hexPattern matches hexadecimal strings such as commit SHAs and checksums, which are
+// high-entropy but not secret.
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above which a token
+// candidate is treated as a secret; random 32-40 character tokens score about 5, long
+// camel-case identifiers about 4.3.
+const minSecretEntropy = 4.5
+
+// shannonEntropy returns the entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactHighEntropy replaces long random-looking tokens, other than hexadecimal strings and
+// tokens without both letters and digits, and reports how many it replaced.
+func redactHighEntropy(text string) (string, int) {
+	n := 0
+	text = tokenCandidatePattern.ReplaceAllStringFunc(text, func(token string) string {
+		if hexPattern.MatchString(token) || !strings.ContainsAny(token, "0123456789") ||
+			strings.Trim(token, "0123456789+/_=-") == "" || shannonEntropy(token) < minSecretEntropy {
+			return token
+		}
+		n++
+		return redactedPlaceholder
+	})
+	return text, n
+}
+
+// redactMessages returns messages with the known secret shapes and high-entropy tokens masked
+// when redactInput is set, logging how many it found, so credentials in a diff never reach
+// an external model endpoint.
+func redactMessages(messages []chatMessage) []chatMessage {
+	if !redactInput {
+		return messages
+	}
+	out := append([]chatMessage(nil), messages...)
+	total := 0
+	for i := range out {
+		var n, m int
+		out[i].Content, n = redactSecrets(out[i].Content)
+		out[i].Content, m = redactHighEntropy(out[i].Content)
+		total += n + m
+	}
+	if total > 0 {
+		log.Printf("Redacted %d secret-looking string(s) from the model input", total)
+	}
+	return out
+}
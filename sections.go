@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// section is one heading-delimited block of a markdown document. The preamble before
+// the first heading is returned as a section with an empty Heading.
+type section struct {
+	Heading string // the heading line including its trailing newline, e.g. "## Summary\n"
+	Body    string // everything up to the next heading
+}
+
+// Title returns the heading text without the leading '#' markers.
+func (s section) Title() string {
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(s.Heading), "#"))
+}
+
+// splitSections splits a markdown document on ATX headings, ignoring '#' lines inside
+// fenced code blocks. joinSections(splitSections(md)) always reproduces md exactly.
+func splitSections(md string) []section {
+	var sections []section
+	current := section{}
+	inFence := false
+
+	for _, line := range strings.SplitAfter(md, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if !inFence && isHeadingLine(trimmed) {
+			if current.Heading != "" || current.Body != "" {
+				sections = append(sections, current)
+			}
+			current = section{Heading: line}
+			continue
+		}
+		current.Body += line
+	}
+	if current.Heading != "" || current.Body != "" {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// joinSections reassembles sections produced by splitSections.
+func joinSections(sections []section) string {
+	var b strings.Builder
+	for _, s := range sections {
+		b.WriteString(s.Heading)
+		b.WriteString(s.Body)
+	}
+	return b.String()
+}
+
+// isHeadingLine reports whether a trimmed line is an ATX heading ("#" through "######").
+func isHeadingLine(trimmed string) bool {
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	return level >= 1 && level <= 6 && (len(trimmed) == level || trimmed[level] == ' ')
+}
+
+// sectionKey normalises a heading title so template and generated headings can be matched.
+func sectionKey(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// canonicalizeHeading maps a heading to the normalised key of the template section it stands
+// for. synonyms maps normalised alternative headings to normalised template headings
+// (e.g. "overview" -> "summary"); unknown headings map to their own key.
+func canonicalizeHeading(h string, synonyms map[string]string) string {
+	key := sectionKey(h)
+	if canonical, ok := synonyms[key]; ok {
+		return canonical
+	}
+	return key
+}
+
+// parseHeadingSynonyms parses "Alternative=Template Heading" pairs into a canonicalizeHeading map.
+func parseHeadingSynonyms(pairs []string) (map[string]string, error) {
+	synonyms := make(map[string]string)
+	for _, pair := range pairs {
+		alt, canonical, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(alt) == "" || strings.TrimSpace(canonical) == "" {
+			return nil, fmt.Errorf("invalid heading synonym %q (want Alternative=Template Heading)", pair)
+		}
+		synonyms[sectionKey(alt)] = sectionKey(canonical)
+	}
+	return synonyms, nil
+}
+
+// sectionsByKey indexes a document's sections by their canonical heading key.
+func sectionsByKey(md string, synonyms map[string]string) map[string]section {
+	index := make(map[string]section)
+	for _, s := range splitSections(md) {
+		if s.Heading != "" {
+			index[canonicalizeHeading(s.Title(), synonyms)] = s
+		}
+	}
+	return index
+}
+
+// htmlCommentPattern matches a (possibly multi-line) HTML comment placeholder.
+var htmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// isPlaceholderOnly reports whether text contains nothing but whitespace and HTML comments.
+func isPlaceholderOnly(text string) bool {
+	return strings.TrimSpace(htmlCommentPattern.ReplaceAllString(text, "")) == ""
+}
+
+// hedgeRestorer holds the configurable phrase list of restoreHedgedSections and the heading
+// synonyms used to find each section's template counterpart.
+type hedgeRestorer struct {
+	phrases  []string          // DIFFSCRIBE_HEDGE_PHRASES
+	synonyms map[string]string // DIFFSCRIBE_HEADING_SYNONYMS
+}
+
+// restoreHedgedSections replaces lines where the model hedged ("cannot be determined from
+// the diff", ...) with the template's placeholder, so such sections still read as unfilled.
+// A hedged line that extends a template line (e.g. "- [ ] Manually tested — steps: ...")
+// is swapped for that template line; any other hedged line is dropped, and a section left
+// with no content falls back to the template's body for that heading.
+func (h hedgeRestorer) restoreHedgedSections(generated, template string) string {
+	hedge := hedgePattern(h.phrases)
+	if hedge == nil {
+		return generated
+	}
+
+	templateSections := sectionsByKey(template, h.synonyms)
+	sections := splitSections(generated)
+	for i, s := range sections {
+		if s.Heading == "" {
+			continue
+		}
+		original, hasOriginal := templateSections[canonicalizeHeading(s.Title(), h.synonyms)]
+
+		var kept []string
+		hedged := false
+		for _, line := range strings.SplitAfter(s.Body, "\n") {
+			loc := hedge.FindStringIndex(line)
+			if loc == nil || strings.Contains(line, "<!--") {
+				kept = append(kept, line)
+				continue
+			}
+			hedged = true
+			if hasOriginal {
+				if replacement, ok := templateLineWithPrefix(original.Body, line[:loc[0]]); ok {
+					kept = append(kept, replacement)
+				}
+			}
+		}
+		if !hedged {
+			continue
+		}
+
+		body := strings.Join(kept, "")
+		if isPlaceholderOnly(body) && hasOriginal {
+			body = original.Body
+		}
+		sections[i].Body = body
+	}
+	return joinSections(sections)
+}
+
+// hedgePattern compiles the phrase list into a single case-insensitive pattern.
+func hedgePattern(phrases []string) *regexp.Regexp {
+	var quoted []string
+	for _, p := range phrases {
+		if p = strings.TrimSpace(p); p != "" {
+			quoted = append(quoted, regexp.QuoteMeta(p))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(` + strings.Join(quoted, "|") + `)`)
+}
+
+// templateLineWithPrefix finds the placeholder line in a template section body whose text
+// before the placeholder comment matches prefix (e.g. "- [ ] Manually tested — steps:").
+func templateLineWithPrefix(templateBody, prefix string) (string, bool) {
+	prefix = strings.TrimRight(strings.TrimSpace(prefix), " :—-")
+	if prefix == "" || strings.Trim(prefix, "-*+[] ") == "" {
+		return "", false
+	}
+	for _, line := range strings.SplitAfter(templateBody, "\n") {
+		idx := strings.Index(line, "<!--")
+		if idx < 0 {
+			continue
+		}
+		if strings.TrimRight(strings.TrimSpace(line[:idx]), " :—-") == prefix {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// reviewChecklistHeading is the heading under which the reviewer checklist snippet is appended.
+const reviewChecklistHeading = "## Reviewer checklist"
+
+// appendReviewChecklist appends checklist under a "## Reviewer checklist" heading unless
+// the body already has such a section, so reruns never duplicate it.
+func appendReviewChecklist(body, checklist string) string {
+	checklist = strings.TrimSpace(checklist)
+	if checklist == "" {
+		return body
+	}
+	if _, exists := sectionsByKey(body, nil)[sectionKey(strings.TrimLeft(reviewChecklistHeading, "# "))]; exists {
+		return body
+	}
+
+	// Allow the snippet file to carry its own heading.
+	if lines := strings.SplitN(checklist, "\n", 2); isHeadingLine(strings.TrimSpace(lines[0])) {
+		checklist = strings.TrimSpace(strings.Join(lines[1:], ""))
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + reviewChecklistHeading + "\n" + checklist + "\n"
+}
+
+// wordPattern matches a single word, skipping bare markdown markers such as "-" or "[ ]".
+var wordPattern = regexp.MustCompile(`\S*[\p{L}\p{N}]\S*`)
+
+// enforceSectionLimits caps every headed section at maxWords words (placeholder comments are
+// not counted). An overlong section is cut at the last sentence boundary within the limit —
+// or at the limit itself when there is none — and ends with an ellipsis.
+func enforceSectionLimits(body string, maxWords int) string {
+	if maxWords <= 0 {
+		return body
+	}
+	sections := splitSections(body)
+	for i, s := range sections {
+		if s.Heading != "" {
+			sections[i].Body = limitSectionWords(s.Body, maxWords)
+		}
+	}
+	return joinSections(sections)
+}
+
+// limitSectionWords applies the enforceSectionLimits word cap to one section body.
+func limitSectionWords(body string, maxWords int) string {
+	lines := strings.SplitAfter(body, "\n")
+	remaining := maxWords
+	for i, line := range lines {
+		words := wordPattern.FindAllStringIndex(htmlCommentPattern.ReplaceAllStringFunc(line, blankOut), -1)
+		if len(words) <= remaining {
+			remaining -= len(words)
+			continue
+		}
+
+		var kept []string
+		if remaining > 0 {
+			kept = append(lines[:i:i], withEllipsis(cutAtSentence(line, words[remaining-1][1]))+"\n")
+		} else {
+			kept = lines[:i:i]
+			for j := len(kept) - 1; j >= 0; j-- {
+				if strings.TrimSpace(kept[j]) != "" {
+					kept[j] = withEllipsis(strings.TrimRight(kept[j], " \n")) + "\n"
+					break
+				}
+			}
+		}
+		return strings.Join(kept, "") + "\n"
+	}
+	return body
+}
+
+// cutAtSentence truncates line at or before byte offset limit, preferring the end of the last
+// complete sentence.
+func cutAtSentence(line string, limit int) string {
+	cut := line[:limit]
+	for i := len(cut) - 1; i > 0; i-- {
+		if strings.ContainsRune(".!?", rune(cut[i])) && (i == len(cut)-1 || cut[i+1] == ' ') {
+			return cut[:i+1]
+		}
+	}
+	return strings.TrimRight(cut, " ,;:")
+}
+
+// withEllipsis marks text as truncated, separating the ellipsis from a finished sentence.
+func withEllipsis(text string) string {
+	if strings.HasSuffix(text, ".") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, "?") {
+		return text + " …"
+	}
+	return text + "…"
+}
+
+// blankOut replaces s with spaces of the same length, keeping byte offsets stable.
+func blankOut(s string) string {
+	return strings.Repeat(" ", len(s))
+}
+
+// mappingFence is the info string of the trailing JSON block in which the model reports,
+// in debug mode, which diff files informed each section.
+const mappingFence = "diffscribe-mapping"
+
+// mappingBlockPattern matches the fenced mapping block.
+var mappingBlockPattern = regexp.MustCompile("(?s)\\n?```" + mappingFence + "[ \\t]*\\n(.*?)\\n?```[ \\t]*\\n?")
+
+// extractAndStripMapping removes the diff-to-section mapping block from the model output and
+// returns it parsed. A missing or malformed block yields a nil mapping.
+func extractAndStripMapping(output string) (clean string, mapping map[string][]string) {
+	loc := mappingBlockPattern.FindStringSubmatchIndex(output)
+	if loc == nil {
+		return output, nil
+	}
+	clean = output[:loc[0]] + output[loc[1]:]
+	if err := json.Unmarshal([]byte(output[loc[2]:loc[3]]), &mapping); err != nil {
+		return clean, nil
+	}
+	return clean, mapping
+}
+
+// mergeAuthorSections keeps every section the author already filled in prBody (present, not
+// just placeholders and different from the template) in place of the generated one, and
+// returns the merged body with the keys of the sections taken from the author.
+func mergeAuthorSections(generated, prBody, template string, synonyms map[string]string) (string, map[string]bool) {
+	authored := authoredSections(prBody, template, synonyms)
+	if len(authored) == 0 {
+		return generated, nil
+	}
+	kept := make(map[string]bool)
+	sections := splitSections(generated)
+	for i, s := range sections {
+		if s.Heading == "" {
+			continue
+		}
+		key := canonicalizeHeading(s.Title(), synonyms)
+		if a, ok := authored[key]; ok {
+			sections[i].Body = a.Body
+			kept[key] = true
+		}
+	}
+	return joinSections(sections), kept
+}
+
+// authoredSections indexes the sections of body that hold the author's own text.
+func authoredSections(body, template string, synonyms map[string]string) map[string]section {
+	templateSections := sectionsByKey(template, synonyms)
+	authored := make(map[string]section)
+	for key, s := range sectionsByKey(body, synonyms) {
+		t, inTemplate := templateSections[key]
+		if isPlaceholderOnly(s.Body) || (inTemplate && strings.Join(strings.Fields(s.Body), " ") == strings.Join(strings.Fields(t.Body), " ")) {
+			continue
+		}
+		authored[key] = s
+	}
+	return authored
+}
+
+// countFilledSections counts the template sections that the generated description actually
+// filled: present, not just placeholders, and different from the template's own text.
+func countFilledSections(generated, template string, synonyms map[string]string) int {
+	generatedSections := sectionsByKey(generated, synonyms)
+	filled := 0
+	for _, s := range splitSections(template) {
+		if s.Heading == "" {
+			continue
+		}
+		g, ok := generatedSections[canonicalizeHeading(s.Title(), synonyms)]
+		if !ok || isPlaceholderOnly(g.Body) {
+			continue
+		}
+		if strings.Join(strings.Fields(g.Body), " ") != strings.Join(strings.Fields(s.Body), " ") {
+			filled++
+		}
+	}
+	return filled
+}
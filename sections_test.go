@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestRestoreHedgedSections(t *testing.T) {
+	template := "## Summary\n<!-- What does this PR do? -->\n\n## Testing\n<!-- How was it tested? -->\n"
+	generated := "## Summary\nAdds a parser.\n\n## Tests\nUnclear from the diff.\n"
+	want := "## Summary\nAdds a parser.\n\n## Tests\n<!-- How was it tested? -->\n"
+
+	h := hedgeRestorer{phrases: []string{"unclear from the diff"}, synonyms: map[string]string{"tests": "testing"}}
+	if got := h.restoreHedgedSections(generated, template); got != want {
+		t.Errorf("restoreHedgedSections =\n%q\nwant\n%q", got, want)
+	}
+	if got := (hedgeRestorer{phrases: defaultHedgePhrases}).restoreHedgedSections(generated, template); got != generated {
+		t.Errorf("a phrase outside the configured list was restored:\n%q", got)
+	}
+}
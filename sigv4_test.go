@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The get-vanilla and get-vanilla-query-order-key-case cases of AWS's SigV4 test suite.
+var (
+	sigv4TestCreds = awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sigv4TestTime  = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestSignAWSRequest(t *testing.T) {
+	tests := []struct {
+		name, url, canonical, stringToSign, signature string
+	}{
+		{
+			name:         "get-vanilla",
+			url:          "https://example.amazonaws.com/",
+			canonical:    "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + emptyPayloadHash,
+			stringToSign: "AWS4-HMAC-SHA256\n20150830T123600Z\n20150830/us-east-1/service/aws4_request\nbb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63",
+			signature:    "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:      "get-vanilla-query-order-key-case",
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			canonical: "GET\n/\nParam1=value1&Param2=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + emptyPayloadHash,
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unsigned := req.Clone(req.Context())
+			unsigned.Header.Set("X-Amz-Date", "20150830T123600Z")
+			canonical, signedHeaders := awsCanonicalRequest(unsigned, emptyPayloadHash)
+			if canonical != tt.canonical {
+				t.Errorf("canonical request =\n%s\nwant\n%s", canonical, tt.canonical)
+			}
+			scope := "20150830/us-east-1/service/aws4_request"
+			if tt.stringToSign != "" {
+				if got := awsStringToSign("20150830T123600Z", scope, canonical); got != tt.stringToSign {
+					t.Errorf("string to sign =\n%s\nwant\n%s", got, tt.stringToSign)
+				}
+			}
+			signAWSRequest(req, nil, sigv4TestCreds, "us-east-1", "service", sigv4TestTime)
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/" + scope + ", SignedHeaders=" + signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAWSCanonicalRequestEncodesModelIDTwice(t *testing.T) {
+	model := "anthropic.claude-3-5-haiku-20241022-v1:0"
+	req, err := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/x/converse", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.URL.Path = "/model/" + model + "/converse"
+	req.URL.RawPath = "/model/" + awsURIEncode(model) + "/converse"
+
+	canonical, _ := awsCanonicalRequest(req, emptyPayloadHash)
+	path := strings.Split(canonical, "\n")[1]
+	if want := "/model/anthropic.claude-3-5-haiku-20241022-v1%253A0/converse"; path != want {
+		t.Errorf("canonical path = %q, want %q", path, want)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// generateSquashMessage asks the model for a squash-merge commit message (subject line plus
+// bulleted body) describing the diff.
+func generateSquashMessage(diff string) (string, error) {
+	prompt := fmt.Sprintf(`Write the squash-merge commit message for the Pull Request with the following diff.
+
+## Code Diff
+%s
+
+## Instructions
+1. First line: an imperative subject of at most 72 characters, without a trailing period.
+2. Then a blank line and a body of 2-6 bullet points ("- ") summarising the notable changes, wrapped at 72 characters.
+3. Return ONLY the commit message, without code fences or commentary.`, diff)
+
+	message, err := chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: "You are an expert software engineer who writes clear, conventional git commit messages."},
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens:   500,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+	return stripCodeFence(message), nil
+}
+
+// postSquashMessage posts the proposed squash commit message in a copyable code block. The
+// REST API cannot preset a PR's squash message, so the comment is the only place for it.
+func postSquashMessage(repo, prNum, message, token string) error {
+	fence := codeFenceFor(message)
+	commentBody := fmt.Sprintf("### 📦 DiffScribe — Suggested Squash Commit Message\n\nCopy this into the commit message box when squash-merging:\n\n%s\n%s\n%s\n\n%s",
+		fence, strings.TrimSpace(message), fence, commentFooter)
+	return postIssueComment(repo, prNum, token, commentBody)
+}
+
+// codeFenceFor returns a backtick fence longer than any backtick run inside text, so text can
+// be wrapped in a code block safely.
+func codeFenceFor(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
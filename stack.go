@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxStackRefs caps how many referenced PRs are looked up.
+const maxStackRefs = 10
+
+// stackRefPattern matches stacked-PR references such as "Depends on #12" or "Stacked on #7".
+var stackRefPattern = regexp.MustCompile(`(?i)\b(?:depends on|stacked on|based on)\s+#(\d+)\b`)
+
+// StackRef is a PR this PR depends on, as referenced from its body.
+type StackRef struct {
+	Number int
+	Title  string // empty when the PR could not be fetched
+}
+
+// resolveStackRefs finds the stacked-PR references in body and fetches their titles, in
+// order of first mention.
+func resolveStackRefs(body, repo, token string) []StackRef {
+	var refs []StackRef
+	seen := make(map[int]bool)
+	for _, m := range stackRefPattern.FindAllStringSubmatch(body, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		if len(refs) == maxStackRefs {
+			break
+		}
+
+		ref := StackRef{Number: n}
+		if pr, err := fetchPullRequest(repo, m[1], token); err != nil {
+			log.Printf("Warning: failed to fetch referenced PR #%d: %v", n, err)
+		} else {
+			ref.Title = pr.Title
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// formatStackNote renders the "Part of a stack" note for the completion comment.
+func formatStackNote(refs []StackRef) string {
+	parts := make([]string, len(refs))
+	for i, ref := range refs {
+		parts[i] = fmt.Sprintf("#%d", ref.Number)
+		if ref.Title != "" {
+			parts[i] += " (" + ref.Title + ")"
+		}
+	}
+	return "**Part of a stack:** " + strings.Join(parts, ", ")
+}
+
+// maxParentBodyBytes bounds how much of the parent PR's description is added to the prompt.
+const maxParentBodyBytes = 2000
+
+// stackedNoteMarker starts the note added to descriptions of PRs stacked on another PR.
+const stackedNoteMarker = "> **Stacked PR:**"
+
+// parentPullRequest returns the open PR whose head branch is this PR's base branch, or nil
+// when the PR targets a branch no open PR comes from.
+func parentPullRequest(rc *runContext) (*PullRequest, error) {
+	pr, err := rc.pullRequest()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := findOpenPullRequestForBranch(rc.cfg.Repository, pr.Base.Ref, rc.cfg.GitHubToken)
+	if err != nil || parent == nil || parent.Number == pr.Number {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// parentContext renders the parent PR's number, title and description (without HTML
+// comments, cut to maxParentBodyBytes) for the prompt.
+func parentContext(parent *PullRequest) string {
+	note := fmt.Sprintf("This PR is stacked on #%d \"%s\" (branch `%s`); the parent's changes are not part of this diff.",
+		parent.Number, parent.Title, parent.Head.Ref)
+	body := strings.TrimSpace(htmlCommentPattern.ReplaceAllString(parent.Body, ""))
+	if len(body) > maxParentBodyBytes {
+		body = strings.ToValidUTF8(body[:maxParentBodyBytes], "") + "\n..."
+	}
+	if body != "" {
+		note += "\n\nParent description:\n" + body
+	}
+	return note
+}
+
+// markStacked appends a note that the PR builds on parent, unless the body already has one.
+func markStacked(body string, parent *PullRequest) string {
+	if parent == nil || strings.Contains(body, stackedNoteMarker) {
+		return body
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + fmt.Sprintf("%s builds on #%d (%s); changes made there are described in that PR.\n",
+		stackedNoteMarker, parent.Number, parent.Title)
+}
+
+// stackParent looks up the parent PR with DIFFSCRIBE_STACK_CONTEXT, or returns nil.
+func stackParent(rc *runContext) *PullRequest {
+	if !rc.cfg.StackContext {
+		return nil
+	}
+	parent, err := parentPullRequest(rc)
+	if err != nil {
+		log.Printf("Warning: failed to look up a parent PR: %v", err)
+		return nil
+	}
+	if parent != nil {
+		log.Printf("PR is stacked on #%d; including it as prompt context", parent.Number)
+	}
+	return parent
+}
+
+// parentPart adds the parent PR to the prompt of a stacked PR.
+func parentPart(parent *PullRequest) promptPart {
+	if parent == nil {
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Parent PR", Text: parentContext(parent)},
+		Instruction:  "This PR builds on the parent PR above: describe only the changes in this diff and do not re-describe the parent's changes.",
+	}
+}
+
+// stackNote lists the PRs the body references as part of its stack with
+// DIFFSCRIBE_STACK_REFS.
+func stackNote(rc *runContext) string {
+	if !rc.cfg.StackRefs {
+		return ""
+	}
+	refs := resolveStackRefs(rc.cfg.PRBody, rc.cfg.Repository, rc.cfg.GitHubToken)
+	if len(refs) == 0 {
+		return ""
+	}
+	return formatStackNote(refs)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// streamResponses asks OpenAI-style providers for streamed completions, and streamIdleTimeout
+// aborts a stream that stops delivering data; both are configured from DIFFSCRIBE_STREAM and
+// DIFFSCRIBE_STREAM_IDLE_TIMEOUT in main.
+var (
+	streamResponses   bool
+	streamIdleTimeout = 30 * time.Second
+)
+
+// streamProgressInterval is how often a running stream logs how much it has received.
+const streamProgressInterval = 10 * time.Second
+
+// errStreamStalled reports a stream that went quiet for longer than streamIdleTimeout; it is
+// retried like an outage.
+var errStreamStalled = errors.New("completion stream stalled")
+
+// readChatStream assembles a streamed chat completion from its server-sent events, logging
+// progress as it goes. body is closed when no data arrives within idleTimeout.
+func readChatStream(body io.ReadCloser, model string, idleTimeout time.Duration) (GenerationResult, error) {
+	var stalled atomic.Bool
+	idle := time.AfterFunc(idleTimeout, func() {
+		stalled.Store(true)
+		body.Close()
+	})
+	defer idle.Stop()
+
+	result := GenerationResult{Model: model}
+	var content strings.Builder
+	start, lastLog := time.Now(), time.Now()
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		idle.Reset(idleTimeout)
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Model   string `json:"model"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *tokenUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return GenerationResult{}, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
+		}
+
+		if time.Since(lastLog) >= streamProgressInterval {
+			log.Printf("Streaming from %s: %d chars in %.0fs...", result.Model, content.Len(), time.Since(start).Seconds())
+			lastLog = time.Now()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if stalled.Load() {
+			return GenerationResult{}, fmt.Errorf("%w: no data for %s after %d chars", errStreamStalled, idleTimeout, content.Len())
+		}
+		return GenerationResult{}, err
+	}
+
+	result.Content = content.String()
+	result.Truncated = result.FinishReason == "length"
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// templateSections returns the titles of the template's headed sections, in order and without
+// duplicates.
+func templateSections(template string) []string {
+	var titles []string
+	seen := make(map[string]bool)
+	for _, s := range splitSections(template) {
+		if s.Heading == "" || seen[sectionKey(s.Title())] {
+			continue
+		}
+		seen[sectionKey(s.Title())] = true
+		titles = append(titles, s.Title())
+	}
+	return titles
+}
+
+// structuredInstruction asks the model for the sections as a JSON object instead of the
+// filled markdown template.
+func structuredInstruction(sections []string) string {
+	keys, _ := json.Marshal(sections)
+	return "Instead of returning the filled template as markdown, return ONLY a JSON object whose keys are exactly these template headings: " +
+		string(keys) + ". Each value is the markdown content of that section, without its heading. Use an empty string for any section you cannot determine from the diff."
+}
+
+// sectionSchema is the JSON schema of the structured output: an object with one string
+// property per section heading, all required.
+func sectionSchema(sections []string) map[string]any {
+	properties := make(map[string]any, len(sections))
+	for _, title := range sections {
+		properties[title] = map[string]string{"type": "string"}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             sections,
+		"additionalProperties": false,
+	}
+}
+
+// renderStructured validates the model's JSON output against the template's sections and
+// renders it as markdown: the template's preamble and headings are kept verbatim, and each
+// section body comes from the JSON, or from the template when the model left it empty. As in
+// sectionSchema, every section is required: missing sections, keys that are not template
+// headings and values that are not strings are errors, so the caller can fall back.
+func renderStructured(output, template string) (string, error) {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "```") {
+		output = strings.TrimPrefix(strings.TrimPrefix(output, "```json"), "```")
+		output = strings.TrimSuffix(strings.TrimSpace(output), "```")
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(output), &fields); err != nil {
+		return "", fmt.Errorf("structured output is not a JSON object: %w", err)
+	}
+
+	titles := templateSections(template)
+	known := make(map[string]bool)
+	for _, title := range titles {
+		known[sectionKey(title)] = true
+	}
+	content := make(map[string]string, len(fields))
+	for key, raw := range fields {
+		if !known[sectionKey(key)] {
+			return "", fmt.Errorf("structured output has section %q, which is not in the template", key)
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil || string(raw) == "null" {
+			return "", fmt.Errorf("structured output section %q is not a string", key)
+		}
+		content[sectionKey(key)] = strings.TrimSpace(value)
+	}
+	var missing []string
+	for _, title := range titles {
+		if _, ok := content[sectionKey(title)]; !ok {
+			missing = append(missing, title)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("structured output is missing section(s) %q", missing)
+	}
+
+	sections := splitSections(template)
+	for i, s := range sections {
+		if text := content[sectionKey(s.Title())]; s.Heading != "" && text != "" {
+			sections[i].Body = "\n" + text + "\n\n"
+		}
+	}
+	return strings.TrimRight(joinSections(sections), "\n") + "\n", nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderStructured(t *testing.T) {
+	const template = "Intro\n\n## Summary\n<!-- What does this PR do? -->\n\n## Testing\n<!-- How was it tested? -->\n"
+	tests := []struct {
+		name, output, want, err string
+	}{
+		{
+			name:   "every section",
+			output: `{"Summary": "Adds a parser.", "Testing": "Unit tests."}`,
+			want:   "Intro\n\n## Summary\n\nAdds a parser.\n\n## Testing\n\nUnit tests.\n",
+		},
+		{
+			name:   "empty section keeps the placeholder",
+			output: "```json\n{\"Summary\": \"Adds a parser.\", \"Testing\": \"\"}\n```",
+			want:   "Intro\n\n## Summary\n\nAdds a parser.\n\n## Testing\n<!-- How was it tested? -->\n",
+		},
+		{name: "missing section", output: `{"Summary": "Adds a parser."}`, err: `missing section(s) ["Testing"]`},
+		{name: "unknown section", output: `{"Summary": "", "Testing": "", "Notes": "x"}`, err: `"Notes", which is not in the template`},
+		{name: "null value", output: `{"Summary": null, "Testing": ""}`, err: `"Summary" is not a string`},
+		{name: "not JSON", output: "## Summary\nAdds a parser.", err: "not a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderStructured(tt.output, template)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want it to mention %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("renderStructured = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// summaryHeadings are the section titles whose content can serve as the comment summary.
+var summaryHeadings = []string{"summary", "description", "overview", "what", "changes"}
+
+// sentencePattern matches one sentence, including its closing punctuation when present.
+var sentencePattern = regexp.MustCompile(`[^.!?]+(?:[.!?]+|$)`)
+
+// commentSummary returns a 2–3 sentence summary of the change for the completion comment. It
+// is taken from the description's summary section when that section was filled; otherwise
+// the model is asked for one in a short extra call.
+func commentSummary(description, template string, synonyms map[string]string) (string, error) {
+	if summary := extractSummary(description, template, synonyms); summary != "" {
+		return summary, nil
+	}
+	summary, err := chatCompletion(completionRequest{
+		Messages: []chatMessage{
+			{Role: "system", Content: "You are an expert software engineer who summarises Pull Requests for reviewers."},
+			{Role: "user", Content: fmt.Sprintf("Summarise the change described by this Pull Request description in 2-3 plain sentences. Return only the sentences.\n\n%s", description)},
+		},
+		MaxTokens:   200,
+		Temperature: 0.3,
+	})
+	if err != nil {
+		return "", err
+	}
+	return firstSentences(summary, 3), nil
+}
+
+// extractSummary returns the first three sentences of the description's summary section, or
+// "" when there is none or it still holds only the template's placeholder.
+func extractSummary(description, template string, synonyms map[string]string) string {
+	sections := sectionsByKey(description, synonyms)
+	templateSections := sectionsByKey(template, synonyms)
+	for _, heading := range summaryHeadings {
+		key := canonicalizeHeading(heading, synonyms)
+		s, ok := sections[key]
+		if !ok || isPlaceholderOnly(s.Body) {
+			continue
+		}
+		if t, ok := templateSections[key]; ok && strings.Join(strings.Fields(t.Body), " ") == strings.Join(strings.Fields(s.Body), " ") {
+			continue
+		}
+		return firstSentences(htmlCommentPattern.ReplaceAllString(s.Body, ""), 3)
+	}
+	return ""
+}
+
+// firstSentences flattens text to a single line, dropping list markers, and keeps at most n
+// sentences.
+func firstSentences(text string, n int) string {
+	var parts []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*+"))
+		if line == "" {
+			continue
+		}
+		if !strings.ContainsAny(line[len(line)-1:], ".!?:") {
+			line += "."
+		}
+		parts = append(parts, line)
+	}
+	sentences := sentencePattern.FindAllString(strings.Join(parts, " "), -1)
+	if len(sentences) > n {
+		sentences = sentences[:n]
+	}
+	for i := range sentences {
+		sentences[i] = strings.TrimSpace(sentences[i])
+	}
+	return strings.Join(sentences, " ")
+}
+
+// summaryNote is the completion comment's summary of the filled description with
+// DIFFSCRIBE_COMMENT_SUMMARY, or "" when no section was filled.
+func summaryNote(rc *runContext, template string) string {
+	if !rc.cfg.CommentSummary || rc.filledSections == 0 {
+		return ""
+	}
+	summary, err := commentSummary(rc.description, template, rc.cfg.HeadingSynonyms)
+	if err != nil {
+		log.Printf("Warning: failed to summarise the PR for the completion comment: %v", err)
+		return ""
+	}
+	if summary == "" {
+		return ""
+	}
+	summary, _ = redactSecrets(summary)
+	return "**Summary:** " + summary
+}
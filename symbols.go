@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// symbolPatterns match declarations per file extension; the last submatch of each pattern is
+// the declared name.
+var symbolPatterns = map[string][]*regexp.Regexp{
+	".go": {
+		regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^type\s+([A-Za-z_]\w*)`),
+	},
+	".py": {
+		regexp.MustCompile(`^\s*(?:async\s+)?def\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*class\s+([A-Za-z_]\w*)`),
+	},
+	".js":  jsSymbolPatterns,
+	".jsx": jsSymbolPatterns,
+	".ts":  jsSymbolPatterns,
+	".tsx": jsSymbolPatterns,
+	".rs": {
+		regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+([A-Za-z_]\w*)`),
+		regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type)\s+([A-Za-z_]\w*)`),
+	},
+	".rb": {
+		regexp.MustCompile(`^\s*def\s+(?:self\.)?([A-Za-z_]\w*[?!]?)`),
+		regexp.MustCompile(`^\s*(?:class|module)\s+([A-Z]\w*)`),
+	},
+	".java": jvmSymbolPatterns,
+	".kt":   jvmSymbolPatterns,
+	".cs":   jvmSymbolPatterns,
+}
+
+// jsSymbolPatterns cover JavaScript and TypeScript declarations.
+var jsSymbolPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?(?:class|interface|type|enum)\s+([A-Za-z_$][\w$]*)`),
+	regexp.MustCompile(`^\s*(?:export\s+)?const\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*=>`),
+}
+
+// jvmSymbolPatterns cover Java, Kotlin and C# type and method declarations.
+var jvmSymbolPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|abstract|sealed|data|open)\s+)*(?:class|interface|enum|record|object|struct)\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|abstract|override|suspend|async|virtual)\s+)*fun\s+([A-Za-z_]\w*)`),
+	regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|abstract|override|async|virtual|synchronized)\s+)+[\w<>\[\],. ]+\s+([A-Za-z_]\w*)\s*\(`),
+}
+
+// fileSymbols are the declarations a diff touches in one file.
+type fileSymbols struct {
+	Path     string
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// extractSymbols finds, for files in languages with symbolPatterns, the declarations the diff
+// adds, removes or modifies. Declarations on changed lines count as added (or removed, or
+// modified when both sides have them); the enclosing declaration git names in a hunk header
+// counts as modified.
+func extractSymbols(diff string) []fileSymbols {
+	var result []fileSymbols
+	for _, f := range splitDiffFiles(diff) {
+		patterns := symbolPatterns[strings.ToLower(path.Ext(f.Path))]
+		if f.Path == "" || len(patterns) == 0 {
+			continue
+		}
+		added, removed, touched := map[string]bool{}, map[string]bool{}, map[string]bool{}
+		_, hunks := splitHunks(f.Text)
+		for _, h := range hunks {
+			lines := strings.Split(h, "\n")
+			if m := hunkHeaderPattern.FindStringSubmatch(lines[0]); m != nil {
+				if name := matchSymbol(patterns, strings.TrimSpace(m[3])); name != "" {
+					touched[name] = true
+				}
+			}
+			for _, line := range lines[1:] {
+				if line == "" || (line[0] != '+' && line[0] != '-') {
+					continue
+				}
+				name := matchSymbol(patterns, line[1:])
+				switch {
+				case name == "":
+				case line[0] == '+':
+					added[name] = true
+				default:
+					removed[name] = true
+				}
+			}
+		}
+
+		fs := fileSymbols{Path: f.Path}
+		for name := range added {
+			if removed[name] {
+				fs.Modified = append(fs.Modified, name)
+			} else {
+				fs.Added = append(fs.Added, name)
+			}
+		}
+		for name := range removed {
+			if !added[name] {
+				fs.Removed = append(fs.Removed, name)
+			}
+		}
+		for name := range touched {
+			if !added[name] && !removed[name] {
+				fs.Modified = append(fs.Modified, name)
+			}
+		}
+		if len(fs.Added)+len(fs.Removed)+len(fs.Modified) == 0 {
+			continue
+		}
+		sort.Strings(fs.Added)
+		sort.Strings(fs.Removed)
+		sort.Strings(fs.Modified)
+		result = append(result, fs)
+	}
+	return result
+}
+
+// matchSymbol returns the name declared on line, or "".
+func matchSymbol(patterns []*regexp.Regexp, line string) string {
+	for _, p := range patterns {
+		if m := p.FindStringSubmatch(line); m != nil {
+			return m[len(m)-1]
+		}
+	}
+	return ""
+}
+
+// formatSymbols renders one bullet per file, e.g.
+// "- `cache/evict.go`: added `Evict`; modified `Get`".
+func formatSymbols(files []fileSymbols) string {
+	var b strings.Builder
+	for _, fs := range files {
+		var parts []string
+		for _, group := range []struct {
+			label string
+			names []string
+		}{{"added", fs.Added}, {"modified", fs.Modified}, {"removed", fs.Removed}} {
+			if len(group.names) > 0 {
+				parts = append(parts, group.label+" `"+strings.Join(group.names, "`, `")+"`")
+			}
+		}
+		fmt.Fprintf(&b, "- `%s`: %s\n", fs.Path, strings.Join(parts, "; "))
+	}
+	return b.String()
+}
+
+// symbolsHeading is the heading of the appended changed-symbols list.
+const symbolsHeading = "## Changed symbols"
+
+// appendSymbols appends list under "## Changed symbols" unless the body already has such a
+// section, so reruns never duplicate it.
+func appendSymbols(body, list string) string {
+	if list == "" {
+		return body
+	}
+	for _, s := range splitSections(body) {
+		if sectionKey(s.Title()) == sectionKey(strings.TrimLeft(symbolsHeading, "# ")) {
+			return body
+		}
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + symbolsHeading + "\n" + list
+}
+
+// changedSymbols extracts the changed symbols of diff when the prompt (DIFFSCRIBE_SYMBOLS) or
+// the description (DIFFSCRIBE_SYMBOLS_SECTION) lists them.
+func changedSymbols(cfg Config, diff string) []fileSymbols {
+	if !cfg.Symbols && !cfg.SymbolsSection {
+		return nil
+	}
+	return extractSymbols(diff)
+}
+
+// symbolsPart lists the changed symbols for the prompt with DIFFSCRIBE_SYMBOLS.
+func symbolsPart(cfg Config, symbols []fileSymbols) promptPart {
+	if !cfg.Symbols || len(symbols) == 0 {
+		return promptPart{}
+	}
+	return promptPart{
+		ContextBlock: ContextBlock{Title: "Changed Symbols", Text: formatSymbols(symbols)},
+		Instruction:  "Refer to the changed functions, types and methods listed above by name where relevant, rather than only to file names.",
+	}
+}
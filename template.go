@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// templatePath is the repository's PR template.
+const templatePath = ".github/pull_request_template.md"
+
+// builtinTemplate stands in for a PR template that exists but was never populated.
+const builtinTemplate = `## Summary
+<!-- Provide a concise description of what this PR does. -->
+
+
+## Changes Made
+<!-- List the key changes introduced by this PR. -->
+
+
+## Testing
+<!-- Describe how you tested your changes. -->
+`
+
+// loadTemplate reads the PR template. When it is empty or whitespace-only the template at
+// defaultPath (DIFFSCRIBE_DEFAULT_TEMPLATE) is used instead, or builtinTemplate when none is
+// configured.
+func loadTemplate(defaultPath string) (string, error) {
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PR template: %w", err)
+	}
+	if strings.TrimSpace(string(data)) != "" {
+		return string(data), nil
+	}
+
+	if defaultPath == "" {
+		log.Printf("Warning: %s is empty; using the built-in default template.", templatePath)
+		return builtinTemplate, nil
+	}
+	log.Printf("Warning: %s is empty; using the default template %s.", templatePath, defaultPath)
+	data, err = os.ReadFile(defaultPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read default PR template: %w", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		log.Printf("Warning: %s is empty too; using the built-in default template.", defaultPath)
+		return builtinTemplate, nil
+	}
+	return string(data), nil
+}
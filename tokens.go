@@ -0,0 +1,211 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// githubModelsInputTokens is the per-request input limit of GitHub Models' standard rate-limit
+// tiers, whatever the model's own context window.
+const githubModelsInputTokens = 8000
+
+// tokenSafetyMargin is the share of the input budget kept in reserve, since approxTokens
+// is a heuristic, not the provider's tokenizer.
+const tokenSafetyMargin = 0.1
+
+// modelContextWindows maps model name fragments to context window sizes in tokens; the first
+// fragment contained in the (lower-cased) model name wins.
+var modelContextWindows = []struct {
+	fragment string
+	tokens   int
+}{
+	{"gpt-4.1", 1047576},
+	{"gpt-5", 400000},
+	{"gpt-4o", 128000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+	{"gemini", 1048576},
+	{"llama", 128000},
+	{"mistral", 32000},
+	{"phi", 128000},
+}
+
+// defaultContextWindow is assumed for models not in modelContextWindows.
+const defaultContextWindow = 8192
+
+// contextWindow returns the context window of model in tokens.
+func contextWindow(model string) int {
+	name := strings.ToLower(model)
+	for _, w := range modelContextWindows {
+		if strings.Contains(name, w.fragment) {
+			return w.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// inputTokenBudget is how many prompt tokens a description request may use:
+// DIFFSCRIBE_INPUT_TOKENS when set, GitHub Models' per-request limit on that provider, and
+// otherwise the model's context window less the tokens reserved for the answer.
+func inputTokenBudget(cfg Config) int {
+	if cfg.InputTokens > 0 {
+		return cfg.InputTokens
+	}
+	if cfg.Provider == defaultProvider {
+		return githubModelsInputTokens
+	}
+	return max(contextWindow(primaryModel)-cfg.MaxTokens, defaultContextWindow-cfg.MaxTokens)
+}
+
+// approxTokens is a heuristic estimate of how many tokens BPE tokenizers such as cl100k_base
+// and o200k_base produce for s. It is not a tokenizer: DiffScribe has no dependencies and
+// does not ship the multi-megabyte vocabularies exact counting needs, so it splits s roughly
+// as those tokenizers pre-tokenize — words with their leading space, short digit groups,
+// punctuation runs and whitespace — and charges each piece by length. Rare words may come out
+// a token or so low; tokenSafetyMargin covers the difference.
+func approxTokens(s string) int {
+	tokens := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r < utf8.RuneSelf && isLetter(byte(r)):
+			n := runLength(s[i:], func(r rune) bool { return r < utf8.RuneSelf && isLetter(byte(r)) })
+			tokens += 1 + (n-1)/5
+			i += n
+		case unicode.IsDigit(r):
+			n := runLength(s[i:], unicode.IsDigit)
+			tokens += (n + 2) / 3
+			i += n
+		case r == ' ' && i+1 < len(s) && s[i+1] != ' ' && s[i+1] != '\n' && s[i+1] != '\t':
+			i += size // a single space joins the following piece
+		case unicode.IsSpace(r):
+			tokens++
+			i += runLength(s[i:], unicode.IsSpace)
+		case r >= utf8.RuneSelf:
+			tokens++ // non-ASCII letters and symbols: about one token per rune
+			i += size
+		default:
+			n := runLength(s[i:], func(r rune) bool {
+				return r < utf8.RuneSelf && !unicode.IsSpace(r) && !unicode.IsDigit(r) && !isLetter(byte(r))
+			})
+			tokens += (n + 1) / 2
+			i += n
+		}
+	}
+	return tokens
+}
+
+// diffSizeLimit is how many bytes of diff fit the prompt budget of cfg after promptTokens,
+// never less than minDiffSize.
+func diffSizeLimit(cfg Config, diff string, promptTokens int) int {
+	return max(diffByteBudget(diff, promptTokens, inputTokenBudget(cfg)), minDiffSize)
+}
+
+// promptDiff reduces a filtered diff to the prompt budget of cfg left after promptTokens: the
+// DIFFSCRIBE_TRUNCATE_STRATEGY reduction to diffSizeLimit bytes, then fitToTokens. It reports
+// whether the diff was reduced. Descriptions and explanations both go through it.
+func promptDiff(cfg Config, diff string, promptTokens int) (string, bool) {
+	reduced, truncated := reduceDiff(diff, diffSizeLimit(cfg, diff, promptTokens), cfg)
+	return fitToTokens(reduced, truncated, minDiffSize, diffTokenBudget(promptTokens, inputTokenBudget(cfg)), cfg.TruncationNotice)
+}
+
+// fitPrompt makes the diff fit the prompt budget left by in, leaving the current body out
+// when the rest of the prompt takes over half the budget. A PR changing more than
+// DIFFSCRIBE_COMMIT_SUMMARY_LINES lines is described from per-commit summaries instead,
+// falling back to promptDiff. It sets in.Diff and rc.truncated and returns the diff.
+func fitPrompt(rc *runContext, in *PromptInput, diff, fullDiff string) string {
+	cfg := rc.cfg
+	lines := changedLineCount(fullDiff)
+	trySummaries := cfg.CommitSummaryLines > 0 && lines > cfg.CommitSummaryLines
+
+	budget := inputTokenBudget(cfg)
+	promptTokens := promptTokenCount(cfg, *in, trySummaries)
+	if promptTokens > budget/2 && in.CurrentBody != "" {
+		log.Printf("Warning: the template, context and current body take ~%d of %d prompt tokens; leaving the current body out", promptTokens, budget)
+		in.CurrentBody = ""
+		promptTokens = promptTokenCount(cfg, *in, trySummaries)
+	}
+	maxSize := diffSizeLimit(cfg, diff, promptTokens)
+	log.Printf("Prompt budget: %d tokens, ~%d for the template and instructions, %d diff bytes", budget, promptTokens, maxSize)
+
+	defer timings.Start("reduce")()
+	var truncated, commitSummaries bool
+	if trySummaries {
+		log.Printf("The PR changes %d lines, over DIFFSCRIBE_COMMIT_SUMMARY_LINES; summarising its commits one by one", lines)
+		if summaries, err := summarizeCommits(rc, maxSize); err != nil {
+			log.Printf("Warning: failed to summarise the commits, reducing the diff instead: %v", err)
+		} else {
+			diff, _ = truncateDiff(summaries, maxSize, cfg.TruncationNotice)
+			truncated, commitSummaries = true, true
+		}
+	}
+	if commitSummaries {
+		log.Printf("Diff replaced by %d chars of commit summaries", len(diff))
+		in.Instructions = append(in.Instructions, commitSummaryInstruction)
+	} else if diff, truncated = promptDiff(cfg, diff, promptTokens); truncated {
+		log.Printf("Diff reduced to %d chars (strategy: %s)", len(diff), cfg.TruncateStrategy)
+	}
+	rc.truncated = truncated
+	in.Diff = diff
+	return diff
+}
+
+// maxRefitPasses bounds how often fitToTokens cuts a reduced diff again.
+const maxRefitPasses = 3
+
+// fitToTokens takes the output of reduceDiff and, while its estimated tokens still exceed
+// available (a reduced diff can be denser than the diff as a whole), cuts it again with
+// cutDiff to a proportionally smaller size, never below minSize, ending it with notice and
+// truncatedMarker. Only the reduced text is cut, so the strategy itself (and the model calls
+// of map-reduce) runs once.
+func fitToTokens(diff string, truncated bool, minSize, available int, notice string) (string, bool) {
+	tail := "\n\n" + notice + "\n" + truncatedMarker
+	for pass := 0; pass < maxRefitPasses && len(diff) > minSize; pass++ {
+		tokens := approxTokens(diff)
+		if tokens <= available {
+			break
+		}
+		size := max(int(int64(len(diff))*int64(max(available, 0))/int64(tokens)), minSize)
+		log.Printf("Reduced diff is ~%d tokens, over the %d available; cutting it to %d bytes", tokens, available, size)
+		if i := strings.LastIndex(diff, "\n"+notice+"\n"+truncatedMarker); i >= 0 {
+			diff = diff[:i]
+		}
+		diff = cutDiff(diff, max(size-len(tail), 0)) + tail
+		truncated = true
+	}
+	return diff, truncated
+}
+
+// runLength returns the byte length of the prefix of s whose runes all satisfy in.
+func runLength(s string, in func(rune) bool) int {
+	for i, r := range s {
+		if !in(r) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// diffTokenBudget is how many tokens of budget are left for the diff after promptTokens and
+// the safety margin.
+func diffTokenBudget(promptTokens, budget int) int {
+	return int(float64(budget)*(1-tokenSafetyMargin)) - promptTokens
+}
+
+// diffByteBudget converts the tokens left after promptTokens into the number of diff bytes
+// that fit, going by the diff's own bytes-per-token ratio.
+func diffByteBudget(diff string, promptTokens, budget int) int {
+	available := diffTokenBudget(promptTokens, budget)
+	if available <= 0 {
+		return 0
+	}
+	tokens := approxTokens(diff)
+	if tokens <= available {
+		return len(diff)
+	}
+	return int(int64(len(diff)) * int64(available) / int64(tokens))
+}
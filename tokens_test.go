@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApproxTokens(t *testing.T) {
+	// Counts of the cl100k_base tokenizer. approxTokens is a heuristic, so it is only held to
+	// within one token below and at most half again above them.
+	tests := []struct {
+		text string
+		bpe  int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"Hello, world!", 4},
+		{"tiktoken is great!", 6},
+		{"The quick brown fox jumps over the lazy dog", 9},
+		{"func main() {", 4},
+	}
+	for _, tt := range tests {
+		if got := approxTokens(tt.text); got < tt.bpe-1 || got > tt.bpe+tt.bpe/2 {
+			t.Errorf("approxTokens(%q) = %d, not close to the tokenizer's %d", tt.text, got, tt.bpe)
+		}
+	}
+	if short, long := approxTokens("+line\n"), approxTokens(strings.Repeat("+line\n", 100)); long < 50*short {
+		t.Errorf("approxTokens does not grow with the text: %d for one line, %d for 100", short, long)
+	}
+}
+
+func TestDiffByteBudget(t *testing.T) {
+	diff := strings.Repeat("+added a line of code here\n", 400)
+	if got := diffByteBudget(diff, 0, 1<<20); got != len(diff) {
+		t.Errorf("diff within the budget: got %d bytes, want all %d", got, len(diff))
+	}
+	if got := diffByteBudget(diff, 1000, 1000); got != 0 {
+		t.Errorf("prompt filling the budget: got %d bytes, want 0", got)
+	}
+	budget := 1000
+	got := diffByteBudget(diff, 100, budget)
+	if got <= 0 || got >= len(diff) {
+		t.Fatalf("diffByteBudget = %d, want a part of the %d byte diff", got, len(diff))
+	}
+	if tokens := approxTokens(diff[:got]); tokens > diffTokenBudget(100, budget) {
+		t.Errorf("%d bytes are ~%d tokens, over the %d available", got, tokens, diffTokenBudget(100, budget))
+	}
+}
+
+func TestFitToTokens(t *testing.T) {
+	const notice = "... (diff truncated)"
+	diff := testDiff(20, 30)
+	reduced, truncated := truncateDiff(diff, len(diff)/2, notice)
+
+	available := approxTokens(reduced) / 3
+	got, gotTruncated := fitToTokens(reduced, truncated, 100, available, notice)
+	if !gotTruncated || len(got) >= len(reduced) {
+		t.Fatalf("fitToTokens kept %d of %d bytes, want the reduced diff cut again", len(got), len(reduced))
+	}
+	if tokens := approxTokens(got); tokens > available {
+		t.Errorf("refitted diff is ~%d tokens, over the %d available", tokens, available)
+	}
+	if n := strings.Count(got, truncatedMarker); n != 1 || !strings.HasSuffix(got, notice+"\n"+truncatedMarker) {
+		t.Errorf("refitted diff has %d marker(s), want exactly one after the notice:\n%s", n, got[max(len(got)-200, 0):])
+	}
+	if !strings.HasPrefix(got, reduced[:100]) {
+		t.Error("the refit did not keep the start of the reduced diff")
+	}
+
+	if again, _ := fitToTokens(reduced, truncated, 100, approxTokens(reduced), notice); again != reduced {
+		t.Error("a reduced diff within the budget was changed")
+	}
+}
+
+func TestPromptTokenCount(t *testing.T) {
+	in := PromptInput{Template: "## Summary\n<!-- describe -->\n", Title: "Add a parser"}
+	base := promptTokenCount(Config{}, in, false)
+
+	in.Diff = strings.Repeat("+code\n", 100)
+	if got := promptTokenCount(Config{}, in, false); got != base {
+		t.Errorf("the diff was counted: %d tokens, want %d", got, base)
+	}
+	in.Instructions = []string{"Keep each section under 50 words."}
+	withInstruction := promptTokenCount(Config{}, in, false)
+	if withInstruction <= base {
+		t.Errorf("an instruction added no tokens: %d, was %d", withInstruction, base)
+	}
+	if got := promptTokenCount(Config{}, in, true); got <= withInstruction {
+		t.Errorf("the commit summary instruction added no tokens: %d, was %d", got, withInstruction)
+	}
+	if len(in.Instructions) != 1 {
+		t.Errorf("promptTokenCount changed the instructions: %q", in.Instructions)
+	}
+	in.Sections = []string{"Summary"}
+	if got := promptTokenCount(Config{}, in, false); got <= withInstruction {
+		t.Errorf("the structured output schema added no tokens: %d, was %d", got, withInstruction)
+	}
+}
+
+func TestPromptDiff(t *testing.T) {
+	cfg := Config{InputTokens: 2000, TruncateStrategy: strategyHead, TruncationNotice: "... (diff truncated)"}
+	diff := testDiff(40, 30)
+	got, truncated := promptDiff(cfg, diff, 500)
+	if !truncated || len(got) >= len(diff) {
+		t.Fatalf("promptDiff kept %d of %d bytes, want the diff reduced", len(got), len(diff))
+	}
+	if tokens := approxTokens(got); tokens > diffTokenBudget(500, cfg.InputTokens) {
+		t.Errorf("reduced diff is ~%d tokens, over the %d left", tokens, diffTokenBudget(500, cfg.InputTokens))
+	}
+	small := testDiff(1, 3)
+	if got, truncated := promptDiff(cfg, small, 500); truncated || got != small {
+		t.Error("a diff within the budget was changed")
+	}
+}
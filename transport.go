@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRedirects bounds how many redirects sendRequest follows for a single call.
+const maxRedirects = 5
+
+// apiLimiter throttles all outbound requests; it is configured from DIFFSCRIBE_RPM in main.
+var apiLimiter *rateLimiter
+
+// httpClient leaves redirects to sendRequest, which replays them without losing the method,
+// body or credentials, or follows them as GET for 302 and 303.
+var httpClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// rateLimitRetries is how often a rate-limited request (429, or GitHub's 403 secondary rate
+// limit) is retried; it is configured from DIFFSCRIBE_RATE_LIMIT_RETRIES in main.
+var rateLimitRetries = 3
+
+// maxRateLimitWait caps a single rate-limit back-off, whatever the response asks for.
+const maxRateLimitWait = 2 * time.Minute
+
+// sendRequest is the shared transport for every GitHub REST and Models API call. It follows
+// 301/307/308 redirects (e.g. a renamed repository) with the original method and body and
+// 302/303 redirects as a GET, keeping credential headers only when the target is the same
+// host or another GitHub host over the same scheme, and backs off and retries rate-limited requests.
+func sendRequest(req *http.Request) (*http.Response, error) {
+	retries := 0
+	for hops := 0; ; hops++ {
+		apiLimiter.Wait()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if wait, limited := rateLimitWait(resp, retries); limited && retries < rateLimitRetries {
+			if next, ok := rewindRequest(req); ok {
+				resp.Body.Close()
+				retries++
+				log.Printf("Rate limited (%d) on %s %s; retrying in %s (%d/%d)", resp.StatusCode, req.Method, req.URL, wait, retries, rateLimitRetries)
+				time.Sleep(wait)
+				req = next
+				hops--
+				continue
+			}
+		}
+
+		if !isFollowedRedirect(resp.StatusCode) || hops >= maxRedirects {
+			return resp, nil
+		}
+
+		location, err := resp.Location()
+		if err != nil {
+			return resp, nil
+		}
+		next, ok := redirectRequest(req, resp.StatusCode, location)
+		if !ok {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		log.Printf("Followed %d redirect: %s %s -> %s (repository may have been renamed or moved)",
+			resp.StatusCode, req.Method, req.URL, location)
+		req = next
+	}
+}
+
+// rateLimitWait reports whether resp is a rate-limit rejection — a 429, or a 403 that carries
+// Retry-After, an exhausted X-RateLimit-Remaining or GitHub's "secondary rate limit" message —
+// and how long to wait before the next attempt. The body of a 403 is read to check the message
+// and replaced, so callers still see it. Without a hint the wait doubles from 15s per retry.
+// An exhausted quota that resets more than maxRateLimitWait away is not retried.
+func rateLimitWait(resp *http.Response, retries int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+	case http.StatusForbidden:
+		if resp.Header.Get("Retry-After") == "" && resp.Header.Get("X-RateLimit-Remaining") != "0" {
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+			if err != nil || !strings.Contains(strings.ToLower(string(data)), "secondary rate limit") {
+				return 0, false
+			}
+		}
+	default:
+		return 0, false
+	}
+
+	wait := (15 * time.Second) << retries
+	if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		wait = after
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if wait = time.Until(time.Unix(reset, 0)) + time.Second; wait > maxRateLimitWait {
+				return 0, false // the quota resets too late for any retry to succeed
+			}
+		}
+	}
+	return min(max(wait, time.Second), maxRateLimitWait), true
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an HTTP date; it
+// returns false when v is empty or malformed.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// rewindRequest clones req for another attempt, rewinding its body. It returns false when the
+// body cannot be replayed.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	next := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		next.Body = body
+	}
+	return next, true
+}
+
+// isFollowedRedirect reports whether status is a redirect sendRequest follows.
+func isFollowedRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// credentialHeaders carry credentials: bearer tokens, the Azure OpenAI, Anthropic and Gemini
+// API keys, AWS session tokens and cookies.
+var credentialHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Api-Key", "X-Api-Key", "X-Goog-Api-Key",
+	"X-Amz-Security-Token", "Cookie",
+}
+
+// redirectRequest clones req for location after a redirect with the given status. A 302 or
+// 303 becomes a bodiless GET (HEAD stays HEAD); other redirects keep the method and rewind
+// the body, and return false when it cannot be replayed. Credential headers are dropped when
+// location is not a trusted host.
+func redirectRequest(req *http.Request, status int, location *url.URL) (*http.Request, bool) {
+	var next *http.Request
+	if (status == http.StatusFound || status == http.StatusSeeOther) && req.Method != http.MethodHead {
+		next = req.Clone(req.Context())
+		next.Method = http.MethodGet
+		next.Body, next.GetBody, next.ContentLength = nil, nil, 0
+		next.Header.Del("Content-Type")
+	} else {
+		var ok bool
+		if next, ok = rewindRequest(req); !ok {
+			return nil, false
+		}
+	}
+	next.URL = location
+	next.Host = ""
+
+	if !trustedRedirectHost(req.URL, location) {
+		for _, h := range credentialHeaders {
+			next.Header.Del(h)
+		}
+	}
+	return next, true
+}
+
+// githubHosts are the hosts that take the GitHub token: the REST API and GitHub Models.
+var githubHosts = []string{hostOf(githubAPIBase), hostOf(githubModelsBase)}
+
+// hostOf returns the host of rawURL, or "" when it does not parse.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// trustedRedirectHost reports whether credentials may follow a redirect from one URL to
+// another: the scheme must not change, and the host must stay the same or move between GitHub
+// hosts. A provider redirecting to api.github.com does not get its key sent there.
+func trustedRedirectHost(from, to *url.URL) bool {
+	if to.Scheme != from.Scheme {
+		return false
+	}
+	return to.Host == from.Host || slices.Contains(githubHosts, from.Host) && slices.Contains(githubHosts, to.Host)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendRequestFollowsRenamedRepoRedirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/old-owner/old-name/pulls/1":
+			http.Redirect(w, r, "/repos/new-owner/new-name/pulls/1", http.StatusMovedPermanently)
+		case "/repos/new-owner/new-name/pulls/1":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPatch || string(body) != `{"body":"x"}` {
+				t.Errorf("redirected request = %s %q, want PATCH with the original body", r.Method, body)
+			}
+			if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+				t.Errorf("Authorization = %q, want it kept on the same host", got)
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPatch, srv.URL+"/repos/old-owner/old-name/pulls/1", strings.NewReader(`{"body":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Request.URL.Path; got != "/repos/new-owner/new-name/pulls/1" {
+		t.Errorf("effective URL path = %q, want the renamed repository", got)
+	}
+}
+
+func TestSendRequestDropsCredentialsOnCrossHostRedirect(t *testing.T) {
+	var got http.Header
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/elsewhere", http.StatusTemporaryRedirect)
+	}))
+	defer origin.Close()
+
+	req, err := http.NewRequest(http.MethodGet, origin.URL+"/repos/o/r", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := map[string]string{
+		"Authorization":  "Bearer gh-secret",
+		"api-key":        "azure-secret",
+		"x-api-key":      "anthropic-secret",
+		"x-goog-api-key": "gemini-secret",
+		"Cookie":         "session=secret",
+	}
+	for k, v := range secrets {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got == nil {
+		t.Fatal("redirect was not followed")
+	}
+	for k := range secrets {
+		if v := got.Get(k); v != "" {
+			t.Errorf("%s = %q forwarded to another host", k, v)
+		}
+	}
+	if got.Get("Accept") != "application/vnd.github+json" {
+		t.Errorf("Accept = %q, want non-credential headers kept", got.Get("Accept"))
+	}
+}
+
+func TestSendRequestFollowsSeeOtherAsGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/submit":
+			http.Redirect(w, r, "/result", http.StatusSeeOther)
+		case "/result":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodGet || len(body) != 0 {
+				t.Errorf("redirected request = %s with %d body bytes, want a bodiless GET", r.Method, len(body))
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/submit", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// secondaryRateLimitBody is the body of GitHub's secondary rate limit 403.
+const secondaryRateLimitBody = `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again.","documentation_url":"https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits"}`
+
+func TestRateLimitWait(t *testing.T) {
+	// Waits derived from the clock are checked to within a second.
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		body    string
+		limited bool
+		wait    time.Duration
+	}{
+		{name: "429 with Retry-After", status: 429, header: map[string]string{"Retry-After": "7"}, limited: true, wait: 7 * time.Second},
+		{name: "429 without hint", status: 429, limited: true, wait: 15 * time.Second},
+		{name: "secondary rate limit 403", status: 403, body: secondaryRateLimitBody, limited: true, wait: 15 * time.Second},
+		{name: "403 with Retry-After", status: 403, header: map[string]string{"Retry-After": "30"}, limited: true, wait: 30 * time.Second},
+		{name: "429 with an HTTP-date Retry-After", status: 429, header: map[string]string{"Retry-After": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, limited: true, wait: maxRateLimitWait},
+		{name: "429 with a past HTTP-date Retry-After", status: 429, header: map[string]string{"Retry-After": "Wed, 21 Oct 2015 07:28:00 GMT"}, limited: true, wait: time.Second},
+		{name: "exhausted quota resetting soon", status: 403, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)}, limited: true, wait: 30 * time.Second},
+		{name: "exhausted quota resetting in an hour", status: 403, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)}},
+		{name: "permission 403", status: 403, body: `{"message":"Resource not accessible by integration"}`},
+		{name: "500", status: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(tt.body))}
+			for k, v := range tt.header {
+				resp.Header.Set(k, v)
+			}
+			wait, limited := rateLimitWait(resp, 0)
+			if limited != tt.limited || (wait-tt.wait).Abs() > time.Second {
+				t.Errorf("rateLimitWait = %s, %v; want %s, %v", wait, limited, tt.wait, tt.limited)
+			}
+			if body, _ := io.ReadAll(resp.Body); string(body) != tt.body {
+				t.Errorf("body after rateLimitWait = %q, want it preserved", body)
+			}
+		})
+	}
+}
+
+func TestSendRequestRetriesSecondaryRateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, secondaryRateLimitBody)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := sendRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("status = %d after %d call(s), want 200 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestRedirectRequestCredentials(t *testing.T) {
+	tests := []struct {
+		name, from, to string
+		keep           bool
+	}{
+		{name: "same host", from: "https://api.github.com/repos/o/r", to: "https://api.github.com/repositories/1", keep: true},
+		{name: "GitHub Models to the REST API", from: githubModelsBase + "/chat/completions", to: "https://api.github.com/x", keep: true},
+		{name: "provider to api.github.com", from: "https://api.openai.com/v1/chat/completions", to: "https://api.github.com/x"},
+		{name: "provider to another host", from: "https://api.anthropic.com/v1/messages", to: "https://evil.example/v1/messages"},
+		{name: "https to http on the same host", from: "https://api.github.com/repos/o/r", to: "http://api.github.com/repos/o/r"},
+		{name: "https to http on the same Jira host", from: "https://jira.example/rest", to: "http://jira.example/rest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.from, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("api-key", "azure-secret")
+			location, err := url.Parse(tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, ok := redirectRequest(req, http.StatusTemporaryRedirect, location)
+			if !ok {
+				t.Fatal("redirectRequest refused a bodiless request")
+			}
+			for _, h := range []string{"Authorization", "api-key"} {
+				if kept := next.Header.Get(h) != ""; kept != tt.keep {
+					t.Errorf("%s kept = %v, want %v", h, kept, tt.keep)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got, ok := parseRetryAfter("12"); !ok || got != 12*time.Second {
+		t.Errorf("parseRetryAfter(\"12\") = %s, %v", got, ok)
+	}
+	if got, ok := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); !ok || got <= 50*time.Second || got > time.Minute {
+		t.Errorf("HTTP date a minute away = %s, %v", got, ok)
+	}
+	for _, v := range []string{"", "soon", "-1"} {
+		if _, ok := parseRetryAfter(v); ok {
+			t.Errorf("parseRetryAfter(%q) accepted", v)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// modelPrice is the list price of a model in USD per million tokens.
+type modelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// modelPrices maps model name fragments to list prices; the first fragment contained in the
+// (lower-cased) model name wins, so more specific fragments come first. DIFFSCRIBE_MODEL_PRICES
+// entries, matched by exact model name, take precedence.
+var modelPrices = []struct {
+	fragment string
+	price    modelPrice
+}{
+	{"gpt-4o-mini", modelPrice{0.15, 0.60}},
+	{"gpt-4o", modelPrice{2.50, 10.00}},
+	{"gpt-4.1-nano", modelPrice{0.10, 0.40}},
+	{"gpt-4.1-mini", modelPrice{0.40, 1.60}},
+	{"gpt-4.1", modelPrice{2.00, 8.00}},
+	{"gpt-5-nano", modelPrice{0.05, 0.40}},
+	{"gpt-5-mini", modelPrice{0.25, 2.00}},
+	{"gpt-5", modelPrice{1.25, 10.00}},
+	{"o4-mini", modelPrice{1.10, 4.40}},
+	{"o3-mini", modelPrice{1.10, 4.40}},
+	{"o3", modelPrice{2.00, 8.00}},
+	{"claude-3-5-haiku", modelPrice{0.80, 4.00}},
+	{"haiku", modelPrice{1.00, 5.00}},
+	{"sonnet", modelPrice{3.00, 15.00}},
+	{"opus", modelPrice{15.00, 75.00}},
+	{"gemini-2.5-flash", modelPrice{0.30, 2.50}},
+	{"gemini-2.5-pro", modelPrice{1.25, 10.00}},
+}
+
+// customPrices are the DIFFSCRIBE_MODEL_PRICES overrides; they are configured in main.
+var customPrices map[string]modelPrice
+
+// priceOf returns the price of model and whether one is known.
+func priceOf(model string) (modelPrice, bool) {
+	if p, ok := customPrices[model]; ok {
+		return p, true
+	}
+	name := strings.ToLower(model)
+	for _, p := range modelPrices {
+		if strings.Contains(name, p.fragment) {
+			return p.price, true
+		}
+	}
+	return modelPrice{}, false
+}
+
+// parseModelPrices converts the DIFFSCRIBE_MODEL_PRICES object, e.g.
+// {"my-model": {"prompt": 0.5, "completion": 1.5}}, into prices per model.
+func parseModelPrices(raw map[string]any) (map[string]modelPrice, error) {
+	prices := make(map[string]modelPrice, len(raw))
+	for model, v := range raw {
+		fields, ok := v.(map[string]any)
+		prompt, pok := fields["prompt"].(float64)
+		completion, cok := fields["completion"].(float64)
+		if !ok || !pok || !cok || prompt < 0 || completion < 0 {
+			return nil, fmt.Errorf("DIFFSCRIBE_MODEL_PRICES entry %q must be {\"prompt\": <USD>, \"completion\": <USD>} per million tokens", model)
+		}
+		prices[model] = modelPrice{Prompt: prompt, Completion: completion}
+	}
+	return prices, nil
+}
+
+// usage records the tokens spent per model over the whole process; batch runs report their
+// own share with Since.
+var usage = newUsageMeter()
+
+// usageMeter accumulates token usage and call counts per model.
+type usageMeter struct {
+	mu     sync.Mutex
+	models map[string]modelUsage
+}
+
+// modelUsage is the token usage of one model's calls.
+type modelUsage struct {
+	Calls int `json:"calls"`
+	tokenUsage
+}
+
+func newUsageMeter() *usageMeter {
+	return &usageMeter{models: make(map[string]modelUsage)}
+}
+
+// Record adds one successful call of model.
+func (m *usageMeter) Record(model string, u tokenUsage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mu := m.models[model]
+	mu.Calls++
+	mu.PromptTokens += u.PromptTokens
+	mu.CompletionTokens += u.CompletionTokens
+	mu.TotalTokens += u.TotalTokens
+	m.models[model] = mu
+}
+
+// Snapshot returns a copy of the usage recorded so far.
+func (m *usageMeter) Snapshot() usageReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := make(usageReport, len(m.models))
+	for model, mu := range m.models {
+		r[model] = mu
+	}
+	return r
+}
+
+// Since returns the usage recorded after the snapshot start was taken.
+func (m *usageMeter) Since(start usageReport) usageReport {
+	r := m.Snapshot()
+	for model, before := range start {
+		mu := r[model]
+		mu.Calls -= before.Calls
+		mu.PromptTokens -= before.PromptTokens
+		mu.CompletionTokens -= before.CompletionTokens
+		mu.TotalTokens -= before.TotalTokens
+		if mu.Calls == 0 {
+			delete(r, model)
+		} else {
+			r[model] = mu
+		}
+	}
+	return r
+}
+
+// usageReport is the usage per model.
+type usageReport map[string]modelUsage
+
+// Total sums the usage over all models.
+func (r usageReport) Total() modelUsage {
+	var total modelUsage
+	for _, mu := range r {
+		total.Calls += mu.Calls
+		total.PromptTokens += mu.PromptTokens
+		total.CompletionTokens += mu.CompletionTokens
+		total.TotalTokens += mu.TotalTokens
+	}
+	return total
+}
+
+// Cost estimates the USD cost of the usage and reports whether every model had a known price.
+func (r usageReport) Cost() (float64, bool) {
+	cost, complete := 0.0, true
+	for model, mu := range r {
+		p, ok := priceOf(model)
+		if !ok {
+			complete = false
+			continue
+		}
+		cost += (float64(mu.PromptTokens)*p.Prompt + float64(mu.CompletionTokens)*p.Completion) / 1e6
+	}
+	return cost, complete
+}
+
+// String formats the usage as "3 model call(s) (gpt-4o-mini): 1234 prompt + 456 completion
+// tokens, ~$0.0005"; the cost is left out when a model has no known price.
+func (r usageReport) String() string {
+	models := make([]string, 0, len(r))
+	for model := range r {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	total := r.Total()
+	s := fmt.Sprintf("%d model call(s) (%s): %d prompt + %d completion tokens",
+		total.Calls, strings.Join(models, ", "), total.PromptTokens, total.CompletionTokens)
+	if cost, ok := r.Cost(); ok {
+		s += fmt.Sprintf(", ~$%.4f", cost)
+	}
+	return s
+}